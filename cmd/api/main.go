@@ -1,28 +1,159 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/MatheusBenetti/rate-limiter/config"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/database"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/logger"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/metrics"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/rls"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/tracing"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/webserver"
 	"github.com/redis/go-redis/v9"
 )
 
 func main() {
-	var cfg config.Config
-	viperCfg := config.NewViper("env")
-	viperCfg.ReadViper(&cfg)
-
-	newWebServer := CreateWebServer(
-		&cfg,
-		redis.NewClient(
-			&redis.Options{
-				Addr: fmt.Sprintf("%s:%s", cfg.Redis.Host, cfg.Redis.Port),
-				DB:   cfg.Redis.Db,
-			},
-		),
-	)
-
-	log.Println("Starting web server on port", cfg.App.Port)
-	newWebServer.Start()
+	if err := run(); err != nil {
+		slog.Error("rate limiter stopped with error", "error", err)
+		os.Exit(1)
+	}
+}
+
+// run starts the API and blocks until SIGTERM or SIGINT, the deferred calls release the resources on exit
+func run() error {
+	configFile := flag.String("config", "", "Configuration file, json, yaml or toml. Defaults to env.* in the working directory")
+	flag.Parse()
+
+	appMetrics := metrics.NewMetrics()
+	viperCfg := config.NewViper(*configFile)
+	viperCfg.OnReload(appMetrics.ConfigReloaded)
+	store := viperCfg.ReadViper()
+	// the logger, the tracer, the redis client and the server are built once from the startup configuration
+	cfg := store.Current()
+
+	appLogger, err := logger.New(os.Stderr, cfg.Log)
+	if err != nil {
+		return fmt.Errorf("error configuring the logger: %w", err)
+	}
+	slog.SetDefault(appLogger)
+
+	shutdownTracing, err := tracing.NewProvider(context.Background(), cfg.Tracing)
+	if err != nil {
+		return fmt.Errorf("error starting the tracer provider: %w", err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			slog.Error("error flushing the spans", "error", err)
+		}
+	}()
+
+	redisCli := newRedisClient(cfg.Redis)
+	defer func() {
+		if err := redisCli.Close(); err != nil {
+			slog.Error("error closing the redis client", "error", err)
+		}
+	}()
+	redisCli.AddHook(tracing.NewRedisHook())
+	redisCli.AddHook(database.NewBreaker(
+		cfg.RateLimiter.Breaker.FailureThreshold,
+		time.Duration(cfg.RateLimiter.Breaker.OpenTimeout)*time.Second,
+	))
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	newWebServer, err := CreateWebServer(store, redisCli, appMetrics)
+	if err != nil {
+		return fmt.Errorf("error creating the web server: %w", err)
+	}
+
+	servers := []func(context.Context) error{newWebServer.Start}
+	slog.Info("starting web server", "port", cfg.App.Port)
+	if adaptive := newWebServer.InternalMiddleware.Adaptive; adaptive != nil {
+		servers = append(servers, adaptive.Start)
+	}
+	if cfg.RLS.Enabled() {
+		rlsServer := rls.NewServer(&newWebServer.InternalMiddleware, store, appMetrics)
+		servers = append(servers, func(ctx context.Context) error {
+			return rlsServer.Start(ctx, cfg.RLS.Port, webserver.ShutdownTimeout(cfg.App))
+		})
+		slog.Info("starting rls server", "port", cfg.RLS.Port)
+	}
+
+	if err := serve(ctx, servers...); err != nil {
+		return err
+	}
+
+	slog.Info("servers stopped")
+	return nil
+}
+
+// serve runs the servers until ctx is done, the first one that fails stops the others
+func serve(ctx context.Context, servers ...func(context.Context) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make(chan error, len(servers))
+	for _, start := range servers {
+		go func(start func(context.Context) error) {
+			errs <- start(ctx)
+		}(start)
+	}
+
+	var firstErr error
+	for range servers {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+
+	return firstErr
+}
+
+// newRedisClient connects to redis in the mode set by the configuration
+func newRedisClient(cfg config.Redis) redis.UniversalClient {
+	options := &redis.UniversalOptions{
+		Addrs:            cfg.Addrs,
+		DB:               cfg.Db,
+		MasterName:       cfg.MasterName,
+		SentinelUsername: cfg.SentinelUsername,
+		SentinelPassword: cfg.SentinelPassword,
+		Username:         cfg.Username,
+		Password:         cfg.Password,
+		DialTimeout:      time.Duration(cfg.DialTimeout) * time.Millisecond,
+		ReadTimeout:      time.Duration(cfg.ReadTimeout) * time.Millisecond,
+		WriteTimeout:     time.Duration(cfg.WriteTimeout) * time.Millisecond,
+		PoolSize:         cfg.PoolSize,
+		MinIdleConns:     cfg.MinIdleConns,
+		PoolTimeout:      time.Duration(cfg.PoolTimeout) * time.Millisecond,
+	}
+	if cfg.TLS {
+		options.TLSConfig = &tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			InsecureSkipVerify: cfg.InsecureSkipVerify,
+		}
+	}
+
+	switch cfg.Mode {
+	case config.RedisSentinel:
+		return redis.NewFailoverClient(options.Failover())
+	case config.RedisCluster:
+		return redis.NewClusterClient(options.Cluster())
+	default:
+		options.Addrs = []string{fmt.Sprintf("%s:%s", cfg.Host, cfg.Port)}
+		if options.TLSConfig != nil {
+			options.TLSConfig.ServerName = cfg.Host
+		}
+		return redis.NewClient(options.Simple())
+	}
 }
@@ -2,26 +2,71 @@ package main
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/MatheusBenetti/rate-limiter/config"
 	"github.com/MatheusBenetti/rate-limiter/internal/infra/database"
 	internalHandler "github.com/MatheusBenetti/rate-limiter/internal/infra/handler"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/metrics"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/proxy"
 	"github.com/MatheusBenetti/rate-limiter/internal/infra/webserver"
 	"github.com/MatheusBenetti/rate-limiter/internal/infra/webserver/middleware"
 	"github.com/redis/go-redis/v9"
 )
 
-func CreateWebServer(cfg *config.Config, redisCli *redis.Client) *webserver.WebServer {
-	newWebServer := webserver.NewWebServer(cfg.App.Port)
+// CreateWebServer wires the handlers, the server settings and the routes of the endpoints are taken
+// from the configuration at startup and are not changed by a reload
+func CreateWebServer(store *config.Store, redisCli redis.UniversalClient, appMetrics *metrics.Metrics) (*webserver.WebServer, error) {
+	cfg := store.Current()
+	newWebServer := webserver.NewWebServer(cfg.App)
 	newWebServer.InternalMiddleware = middleware.Middleware{
 		RedisClient: redisCli,
-		Config:      cfg,
+		Config:      store,
+		Metrics:     appMetrics,
+		CIDRBlocks:  database.NewCIDRBlocks(redisCli, database.DefaultCIDRBlocksTTL),
 	}
-	apikeyHandler := internalHandler.NewAPIKeyHandler(database.NewAPIKeyRedis(redisCli))
+	var adaptive internalHandler.AdaptiveState
+	if cfg.RateLimiter.Adaptive.Enabled() {
+		newWebServer.InternalMiddleware.Adaptive = middleware.NewAdaptive(store, appMetrics)
+		adaptive = newWebServer.InternalMiddleware.Adaptive
+	}
+	adminRepository := database.NewAdminRedis(redisCli)
+	appMetrics.WatchBlocked(adminRepository)
+
+	apikeyHandler := internalHandler.NewAPIKeyHandler(appMetrics.ApiKeyRepository(database.NewAPIKeyRedis(redisCli)), store)
+	adminHandler := internalHandler.NewAdminHandler(adminRepository, store, adaptive)
+	healthHandler := internalHandler.NewHealthHandler(database.NewHealthRedis(redisCli), store)
 
 	newWebServer.AddHandler(http.MethodPost, "/generate-api-key", apikeyHandler.CreateAPIKey)
-	newWebServer.AddHandler(http.MethodGet, "/req-by-ip", internalHandler.HelloWorld)
-	newWebServer.AddHandler(http.MethodGet, "/req-by-key", internalHandler.HelloWorldWithAPIKey)
+	if cfg.Proxy.Enabled() {
+		upstreams, err := proxy.NewProxy(cfg.Proxy.Upstreams)
+		if err != nil {
+			return nil, err
+		}
+		newWebServer.AddProxyHandler(upstreams)
+	} else {
+		newWebServer.AddHandler(http.MethodGet, "/req-by-ip", internalHandler.HelloWorld)
+		newWebServer.AddHandler(http.MethodGet, "/req-by-key", internalHandler.HelloWorldWithAPIKey)
+	}
+
+	newWebServer.AddExemptHandler(http.MethodGet, "/admin/blocks", adminHandler.List)
+	newWebServer.AddExemptHandler(http.MethodPost, "/admin/blocks", adminHandler.Block)
+	newWebServer.AddExemptHandler(http.MethodDelete, "/admin/blocks", adminHandler.Unblock)
+	newWebServer.AddExemptHandler(http.MethodPost, "/admin/reset", adminHandler.Reset)
+	newWebServer.AddExemptHandler(http.MethodGet, "/admin/adaptive", adminHandler.Adaptive)
+	if cfg.Check.Enabled() {
+		check := newWebServer.InternalMiddleware.Check(cfg.Check)
+		newWebServer.AddExemptHandler("", cfg.Check.Path, check.ServeHTTP)
+		newWebServer.AddExemptHandler("", strings.TrimSuffix(cfg.Check.Path, "/")+"/*", check.ServeHTTP)
+	}
+	if cfg.Decisions.Enabled() {
+		decisionHandler := internalHandler.NewDecisionHandler(newWebServer.InternalMiddleware.IPRepository, store, appMetrics)
+		newWebServer.AddExemptHandler(http.MethodPost, "/v1/check", decisionHandler.Check)
+		newWebServer.AddExemptHandler(http.MethodPost, "/v1/check:batch", decisionHandler.Batch)
+	}
+	newWebServer.AddExemptHandler(http.MethodGet, cfg.Metrics.Endpoint(), appMetrics.Handler().ServeHTTP)
+	newWebServer.AddExemptHandler(http.MethodGet, cfg.Health.Liveness(), healthHandler.Liveness)
+	newWebServer.AddExemptHandler(http.MethodGet, cfg.Health.Readiness(), healthHandler.Readiness)
 
-	return newWebServer
+	return newWebServer, nil
 }
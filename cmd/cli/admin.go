@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"text/tabwriter"
+
+	"github.com/MatheusBenetti/rate-limiter/internal/dto"
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+)
+
+var adminCommands = map[string]func(args []string) error{
+	"block":   blockCommand,
+	"unblock": unblockCommand,
+	"reset":   resetCommand,
+	"list":    listCommand,
+}
+
+type adminClient struct {
+	server string
+	token  string
+}
+
+func adminFlags(name string) (*flag.FlagSet, *adminClient) {
+	client := &adminClient{}
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.StringVar(&client.server, "server", "http://localhost:8080", "Rate limiter base URL")
+	flags.StringVar(&client.token, "token", os.Getenv("ADMIN_TOKEN"), "Admin token, defaults to $ADMIN_TOKEN")
+	return flags, client
+}
+
+func blockCommand(args []string) error {
+	flags, client := adminFlags("block")
+	kind := flags.String("kind", entity.BlockKindIP, "Identity kind: ip, cidr or api_key")
+	identity := flags.String("identity", "", "IP, CIDR range or API key to block")
+	namespace := flags.String("namespace", "", "Namespace of the limiter holding an ip block, such as failures/login")
+	duration := flags.Int64("duration", 3600, "Block duration in seconds")
+	reason := flags.String("reason", "", "Why the identity is blocked")
+	_ = flags.Parse(args)
+
+	var output dto.BlockOutput
+	if err := client.do(http.MethodPost, "/admin/blocks", dto.BlockInput{
+		Kind:      *kind,
+		Namespace: *namespace,
+		Identity:  *identity,
+		Duration:  *duration,
+		Reason:    *reason,
+	}, &output); err != nil {
+		return err
+	}
+
+	fmt.Printf("Blocked %s %s for %ds\n", output.Kind, output.Identity, output.TTL)
+	return nil
+}
+
+func unblockCommand(args []string) error {
+	flags, client := adminFlags("unblock")
+	kind := flags.String("kind", entity.BlockKindIP, "Identity kind: ip, cidr or api_key")
+	identity := flags.String("identity", "", "IP, CIDR range or API key to unblock")
+	namespace := flags.String("namespace", "", "Namespace of the limiter holding an ip block, such as failures/login")
+	_ = flags.Parse(args)
+
+	query := url.Values{"kind": {*kind}, "namespace": {*namespace}, "identity": {*identity}}
+	if err := client.do(http.MethodDelete, "/admin/blocks?"+query.Encode(), nil, nil); err != nil {
+		return err
+	}
+
+	fmt.Printf("Unblocked %s %s\n", *kind, *identity)
+	return nil
+}
+
+func resetCommand(args []string) error {
+	flags, client := adminFlags("reset")
+	kind := flags.String("kind", entity.BlockKindIP, "Identity kind: ip or api_key")
+	identity := flags.String("identity", "", "IP or API key to reset")
+	namespace := flags.String("namespace", "", "Namespace of the limiter holding an ip block, such as failures/login")
+	_ = flags.Parse(args)
+
+	if err := client.do(http.MethodPost, "/admin/reset", dto.IdentityInput{
+		Kind:      *kind,
+		Namespace: *namespace,
+		Identity:  *identity,
+	}, nil); err != nil {
+		return err
+	}
+
+	fmt.Printf("Reset counters of %s %s\n", *kind, *identity)
+	return nil
+}
+
+func listCommand(args []string) error {
+	flags, client := adminFlags("list")
+	_ = flags.Parse(args)
+
+	var output []dto.BlockOutput
+	if err := client.do(http.MethodGet, "/admin/blocks", nil, &output); err != nil {
+		return err
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "KIND\tNAMESPACE\tIDENTITY\tTTL\tREASON")
+	for _, block := range output {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%ds\t%s\n", block.Kind, block.Namespace, block.Identity, block.TTL, block.Reason)
+	}
+	return writer.Flush()
+}
+
+func (c *adminClient) do(method, path string, input, output any) error {
+	var body io.Reader
+	if input != nil {
+		payload, err := json.Marshal(input)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(method, c.server+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(entity.AdminTokenHeader, c.token)
+
+	response, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= http.StatusBadRequest {
+		message, _ := io.ReadAll(response.Body)
+		return fmt.Errorf("%s: %s", response.Status, bytes.TrimSpace(message))
+	}
+
+	if output == nil {
+		return nil
+	}
+	return json.NewDecoder(response.Body).Decode(output)
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"os"
 	"sync"
 	"time"
 
@@ -13,6 +14,16 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		if command, ok := adminCommands[os.Args[1]]; ok {
+			if err := command(os.Args[2:]); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	var (
 		url        = flag.String("url", "", "URL to test")
 		maxReq     = flag.Int("req", 100, "Maximum amount of requests to send")
@@ -1,28 +1,499 @@
 package config
 
+import (
+	"strings"
+	"time"
+)
+
+const (
+	RedisStandalone = "standalone"
+	RedisSentinel   = "sentinel"
+	RedisCluster    = "cluster"
+)
+
+// Redis connects to a single server at Host and Port by default. Mode "sentinel" asks the sentinels at
+// Addrs for the master named MasterName and "cluster" discovers the cluster from the nodes at Addrs.
+// Timeouts are in milliseconds, zero keeps the defaults of the client, and so do the pool sizes. With TLS
+// the connection is encrypted and InsecureSkipVerify skips the certificate check, meant only for local tests
 type Redis struct {
-	Db   int
-	Host string
-	Port string
+	Mode               string
+	Db                 int
+	Host               string
+	Port               string
+	Addrs              []string
+	MasterName         string
+	SentinelUsername   string
+	SentinelPassword   string
+	Username           string
+	Password           string
+	TLS                bool
+	InsecureSkipVerify bool
+	DialTimeout        int64
+	ReadTimeout        int64
+	WriteTimeout       int64
+	PoolSize           int
+	MinIdleConns       int
+	PoolTimeout        int64
 }
 
+// App timeouts are in seconds. WriteTimeout, off when zero, must leave room for the delay of the queued routes and
+// ShutdownTimeout bounds how long the in-flight requests are drained on SIGTERM
 type App struct {
-	Host string
-	Port string
+	Host              string
+	Port              string
+	ReadTimeout       int64
+	ReadHeaderTimeout int64
+	WriteTimeout      int64
+	IdleTimeout       int64
+	ShutdownTimeout   int64
+}
+
+// DefaultMetricsPath is where the prometheus metrics are served when no path is configured
+const DefaultMetricsPath = "/metrics"
+
+type Metrics struct {
+	Path string
+}
+
+func (m Metrics) Endpoint() string {
+	if m.Path == "" {
+		return DefaultMetricsPath
+	}
+
+	return m.Path
 }
 
+// Paths of the probes when the configuration leaves them out
+const (
+	DefaultLivenessPath  = "/healthz"
+	DefaultReadinessPath = "/readyz"
+)
+
+type Health struct {
+	LivenessPath  string
+	ReadinessPath string
+}
+
+func (h Health) Liveness() string {
+	if h.LivenessPath == "" {
+		return DefaultLivenessPath
+	}
+
+	return h.LivenessPath
+}
+
+func (h Health) Readiness() string {
+	if h.ReadinessPath == "" {
+		return DefaultReadinessPath
+	}
+
+	return h.ReadinessPath
+}
+
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// Log sets the minimum level (debug, info, warn or error) and the format, text or json, of the logs
+type Log struct {
+	Level    string
+	Format   string
+	Sampling LogSampling
+}
+
+// LogSampling lets through the first Initial records with the same message every second and then one every
+// Thereafter, they default to 10 and 100. A negative Initial turns sampling off
+type LogSampling struct {
+	Initial    int
+	Thereafter int
+}
+
+const (
+	TracingOTLP   = "otlp"
+	TracingStdout = "stdout"
+)
+
+// Tracing exports the spans of the limiter to Exporter, "otlp" sends them over HTTP to Endpoint and
+// "stdout" prints them. Tracing is disabled while Exporter is empty
+type Tracing struct {
+	Exporter    string
+	Endpoint    string
+	Insecure    bool
+	ServiceName string
+	SampleRatio float64
+}
+
+const (
+	FailOpen   = "open"
+	FailClosed = "closed"
+)
+
+// RateLimiter.FailMode decides what happens to the requests the limiter can't decide on because
+// its storage is failing: "open" lets them through and "closed", the default, rejects them
 type RateLimiter struct {
-	ByIp LimitValues
+	ByIp        LimitValues
+	FailMode    string
+	Breaker     BreakerValues
+	Concurrency ConcurrencyValues
+	Adaptive    AdaptiveValues
+	Shedding    SheddingValues
+	Plans       map[string]PlanValues
+	Routes      map[string]RouteValues
+}
+
+// PlanValues holds the settings shared by every API key created with the plan, Priority is
+// the shedding class of its keys
+type PlanValues struct {
+	BlockSchedule []int64 `mapstructure:"block_schedule"`
+	OffenseDecay  int64   `mapstructure:"offense_decay"`
+	Priority      string  `mapstructure:"priority"`
+}
+
+// SheddingValues caps the limited requests in flight in each replica at Capacity, zero disables it. Each priority
+// class in Classes may only fill its share, between zero and one, of the capacity, so the higher classes keep
+// the headroom left by the lower ones. A request takes the priority of its route, else the priority of the plan
+// of its API key, else ApiKeyClass when it has a registered key or AnonymousClass when it does not. The class
+// named by its Header is only taken when its share is lower. Requests without a known class get the lowest share
+type SheddingValues struct {
+	Capacity       int
+	Classes        map[string]float64
+	Header         string
+	AnonymousClass string
+	ApiKeyClass    string
+}
+
+func (s SheddingValues) Enabled() bool {
+	return s.Capacity > 0
+}
+
+// Share returns the share of the capacity of the class, classes are matched lowercased since viper lowercases map keys
+func (s SheddingValues) Share(class string) float64 {
+	if share, ok := s.Classes[strings.ToLower(class)]; ok {
+		return share
+	}
+
+	lowest := 1.0
+	for _, share := range s.Classes {
+		lowest = min(lowest, share)
+	}
+	return lowest
+}
+
+// Known reports whether class is one of the configured classes
+func (s SheddingValues) Known(class string) bool {
+	_, ok := s.Classes[strings.ToLower(class)]
+	return ok
+}
+
+// BreakerValues opens the circuit around redis after FailureThreshold consecutive failures for
+// OpenTimeout seconds. With Fallback the requests are limited by the by_ip policy in memory meanwhile
+type BreakerValues struct {
+	FailureThreshold int
+	OpenTimeout      int64
+	Fallback         bool
+}
+
+// ConcurrencyValues caps the simultaneous in-flight requests of a single identity, zero disables the cap.
+// A lease expires after LeaseTTL seconds when the replica holding it stops refreshing it
+type ConcurrencyValues struct {
+	ByIp     int
+	ByApiKey int
+	LeaseTTL int64
+}
+
+// Defaults of the adaptive limits
+const (
+	DefaultAdaptiveDecrease   = 0.5
+	DefaultAdaptiveIncrease   = 0.1
+	DefaultAdaptiveMinFactor  = 0.1
+	DefaultAdaptiveMinSamples = 20
+)
+
+// AdaptiveValues scales the by_ip and API key limits down by Decrease after every Interval, in seconds, in which
+// the p99 latency of the limited handlers went over LatencyThreshold milliseconds or their ratio of 5xx responses
+// went over ErrorRatio, and back up by Increase after every healthy one, never below MinFactor. Intervals with
+// less than MinSamples responses count as healthy. Adaptive limits are disabled while Interval is zero
+type AdaptiveValues struct {
+	Interval         int64
+	LatencyThreshold int64
+	ErrorRatio       float64
+	Decrease         float64
+	Increase         float64
+	MinFactor        float64
+	MinSamples       int
+}
+
+func (a AdaptiveValues) Enabled() bool {
+	return a.Interval > 0
+}
+
+// Healthy reports whether an interval with the given p99 latency and ratio of 5xx responses is healthy
+func (a AdaptiveValues) Healthy(samples int, p99 time.Duration, errorRatio float64) bool {
+	if samples < a.Samples() {
+		return true
+	}
+	if a.LatencyThreshold > 0 && p99 > time.Duration(a.LatencyThreshold)*time.Millisecond {
+		return false
+	}
+
+	return a.ErrorRatio <= 0 || errorRatio <= a.ErrorRatio
+}
+
+func (a AdaptiveValues) DecreaseBy() float64 {
+	return orDefault(a.Decrease, DefaultAdaptiveDecrease)
+}
+
+func (a AdaptiveValues) IncreaseBy() float64 {
+	return orDefault(a.Increase, DefaultAdaptiveIncrease)
+}
+
+func (a AdaptiveValues) Floor() float64 {
+	return orDefault(a.MinFactor, DefaultAdaptiveMinFactor)
+}
+
+func (a AdaptiveValues) Samples() int {
+	if a.MinSamples == 0 {
+		return DefaultAdaptiveMinSamples
+	}
+
+	return a.MinSamples
+}
+
+func orDefault(value, fallback float64) float64 {
+	if value == 0 {
+		return fallback
+	}
+
+	return value
 }
 
+// RouteValues holds the limiter settings of a single route, keyed by its path
+type RouteValues struct {
+	Cost        int           `mapstructure:"cost"`
+	MaxInFlight int           `mapstructure:"max_in_flight"`
+	Delay       DelayValues   `mapstructure:"delay"`
+	Failures    FailureValues `mapstructure:"failures"`
+	Shadow      *LimitValues  `mapstructure:"shadow"`
+	FailMode    string        `mapstructure:"fail_mode"`
+	Priority    string        `mapstructure:"priority"`
+}
+
+// DelayValues makes a request over the limit wait up to MaxDelay milliseconds for the next slot
+// instead of being rejected right away, at most MaxQueued requests of a key wait at the same time
+type DelayValues struct {
+	MaxDelay  int64 `mapstructure:"max_delay_ms"`
+	MaxQueued int   `mapstructure:"max_queued"`
+}
+
+func (d DelayValues) Enabled() bool {
+	return d.MaxDelay > 0
+}
+
+// FailureValues counts only the responses answered with one of the Status codes,
+// blocking the identity once MaxFailures is reached inside the time window
+type FailureValues struct {
+	Status         []int  `mapstructure:"status"`
+	MaxFailures    int    `mapstructure:"max_failures"`
+	TimeWindow     int64  `mapstructure:"time_window"`
+	BlockDuration  int64  `mapstructure:"blocked_duration"`
+	ResetOnSuccess bool   `mapstructure:"reset_on_success"`
+	IdentityHeader string `mapstructure:"identity_header"`
+}
+
+func (f FailureValues) Enabled() bool {
+	return len(f.Status) > 0
+}
+
+func (f FailureValues) Counts(status int) bool {
+	for _, s := range f.Status {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// LimitValues is the policy of a limiter. When BlockSchedule is set, repeat offenders are blocked
+// for each step of the schedule in turn, an offense is forgotten OffenseDecay seconds after the last one
+// Shadow policies are fully evaluated but never enforced, they only report the requests they would block
 type LimitValues struct {
-	MaxReq        int
-	TimeWindow    int64
-	BlockDuration int64
+	MaxReq        int     `mapstructure:"max_requests"`
+	TimeWindow    int64   `mapstructure:"time_window"`
+	BlockDuration int64   `mapstructure:"blocked_duration"`
+	BlockSchedule []int64 `mapstructure:"block_schedule"`
+	OffenseDecay  int64   `mapstructure:"offense_decay"`
+	Shadow        bool    `mapstructure:"shadow"`
+}
+
+// LongestBlock is the longest block, in seconds, the policy gives to a key that goes over the limit
+func (l LimitValues) LongestBlock() int64 {
+	longest := l.BlockDuration
+	for _, step := range l.BlockSchedule {
+		longest = max(longest, step)
+	}
+
+	return longest
+}
+
+// Proxy forwards the requests allowed by the limiter to the upstream whose path prefix is the longest
+// match of the request path. Without upstreams the limiter guards the handlers of the API itself
+type Proxy struct {
+	Upstreams map[string]UpstreamValues
+}
+
+func (p Proxy) Enabled() bool {
+	return len(p.Upstreams) > 0
+}
+
+// UpstreamValues holds the target of a path prefix. StripPrefix drops the prefix before forwarding,
+// SetHeaders and RemoveHeaders rewrite the request headers and Timeout bounds, in milliseconds,
+// how long the upstream takes to answer the headers of the response, zero waits for as long as the server allows
+type UpstreamValues struct {
+	URL           string            `mapstructure:"url"`
+	StripPrefix   bool              `mapstructure:"strip_prefix"`
+	SetHeaders    map[string]string `mapstructure:"set_headers"`
+	RemoveHeaders []string          `mapstructure:"remove_headers"`
+	Timeout       int64             `mapstructure:"timeout_ms"`
+}
+
+// Edge proxies the check endpoint can sit behind
+const (
+	CheckEnvoy = "envoy"
+	CheckNginx = "nginx"
+)
+
+// Denied status of the checks when the configuration leaves it out, nginx auth_request turns any status
+// other than 401 and 403 into a 500
+const (
+	DefaultCheckDeniedStatus      = 429
+	DefaultNginxCheckDeniedStatus = 403
+)
+
+// Check serves the decision endpoint used by edge proxies, Envoy ext_authz and nginx auth_request, under Path.
+// It is disabled while Path is empty since it trusts the forwarded headers. Proxy, envoy by default, picks
+// the headers the original request is read from, only the ones that proxy sets itself are trusted
+type Check struct {
+	Path         string
+	Proxy        string
+	DeniedStatus int
+}
+
+func (c Check) Enabled() bool {
+	return c.Path != ""
+}
+
+func (c Check) Nginx() bool {
+	return c.Proxy == CheckNginx
+}
+
+func (c Check) Denied() int {
+	if c.DeniedStatus != 0 {
+		return c.DeniedStatus
+	}
+	if c.Nginx() {
+		return DefaultNginxCheckDeniedStatus
+	}
+
+	return DefaultCheckDeniedStatus
+}
+
+// RLS serves the Envoy rate limit service over gRPC on Port, it is disabled while Port is empty.
+// Domains holds the descriptor rules of each rate limit domain configured in Envoy
+type RLS struct {
+	Port    string
+	Domains map[string][]DescriptorValues
+}
+
+func (r RLS) Enabled() bool {
+	return r.Port != ""
+}
+
+// DescriptorValues limits the Envoy descriptors whose entries match Entries in order by Policy. An entry
+// without Value matches any value of its key and every value is limited apart. Name labels the rule
+type DescriptorValues struct {
+	Name    string        `mapstructure:"name"`
+	Entries []EntryValues `mapstructure:"entries"`
+	Policy  LimitValues   `mapstructure:"policy"`
+}
+
+type EntryValues struct {
+	Key   string `mapstructure:"key"`
+	Value string `mapstructure:"value"`
+}
+
+// Grpc holds the policies the gRPC interceptors apply to single methods. A method without a policy
+// of its own is limited by its API key or else by the by_ip policy
+type Grpc struct {
+	Methods []MethodValues
+}
+
+// Method returns the policy of the full method name, as in /package.Service/Method
+func (g Grpc) Method(fullMethod string) (MethodValues, bool) {
+	for _, m := range g.Methods {
+		if m.Method == fullMethod {
+			return m, true
+		}
+	}
+
+	return MethodValues{}, false
+}
+
+type MethodValues struct {
+	Method string      `mapstructure:"method"`
+	Policy LimitValues `mapstructure:"policy"`
+}
+
+// Decisions serves the JSON decision API under /v1 to the services that can't embed the limiter, it is
+// disabled while there are no Policies. The callers name one of the Policies, keyed by name, in each
+// descriptor, and must send Token, required along with the Policies, in the X-Api-Token header
+type Decisions struct {
+	Token    string
+	Policies map[string]LimitValues
+}
+
+func (d Decisions) Enabled() bool {
+	return len(d.Policies) > 0
+}
+
+// Policy returns the policy named name, names are matched lowercased since viper lowercases map keys
+func (d Decisions) Policy(name string) (LimitValues, bool) {
+	policy, ok := d.Policies[strings.ToLower(name)]
+	return policy, ok
+}
+
+// Admin protects the admin endpoints, they are disabled while Token is empty
+type Admin struct {
+	Token string
 }
 
 type Config struct {
 	Redis       Redis
 	App         App
+	Admin       Admin
+	Log         Log
+	Health      Health
+	Metrics     Metrics
+	Tracing     Tracing
+	Proxy       Proxy
+	Check       Check
+	RLS         RLS
+	Grpc        Grpc
+	Decisions   Decisions
 	RateLimiter RateLimiter
 }
+
+// Route returns the settings configured for path, the zero value when there are none.
+// Paths are matched lowercased since viper lowercases map keys
+func (rl RateLimiter) Route(path string) RouteValues {
+	return rl.Routes[strings.ToLower(path)]
+}
+
+// FailsOpen reports whether the requests of the route go through when the limiter can't decide on them
+func (rl RateLimiter) FailsOpen(route RouteValues) bool {
+	if route.FailMode != "" {
+		return route.FailMode == FailOpen
+	}
+
+	return rl.FailMode == FailOpen
+}
@@ -0,0 +1,24 @@
+package config
+
+import "sync/atomic"
+
+// Store holds the configuration in use. Reloads swap it as a whole, so a request that took
+// the current configuration keeps reading the same values until it finishes
+type Store struct {
+	current atomic.Pointer[Config]
+}
+
+func NewStore(config *Config) *Store {
+	s := &Store{}
+	s.current.Store(config)
+	return s
+}
+
+// Current returns the configuration in use, it must not be modified
+func (s *Store) Current() *Config {
+	return s.current.Load()
+}
+
+func (s *Store) swap(config *Config) {
+	s.current.Store(config)
+}
@@ -0,0 +1,249 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Validate reports the first setting that keeps the limiter from running with the configuration
+func (c *Config) Validate() error {
+	if c.App.Port == "" {
+		return errors.New("app.port is required")
+	}
+
+	if err := c.Redis.validate(); err != nil {
+		return err
+	}
+
+	if err := c.RateLimiter.ByIp.validate("rate_limiter.by_ip"); err != nil {
+		return err
+	}
+	if err := validateFailMode("rate_limiter.fail_mode", c.RateLimiter.FailMode); err != nil {
+		return err
+	}
+
+	if err := c.RateLimiter.Adaptive.validate(); err != nil {
+		return err
+	}
+
+	if err := c.RateLimiter.Shedding.validate(); err != nil {
+		return err
+	}
+	for name, plan := range c.RateLimiter.Plans {
+		if err := c.RateLimiter.Shedding.validateClass(fmt.Sprintf("rate_limiter.plans.%s.priority", name), plan.Priority); err != nil {
+			return err
+		}
+	}
+
+	for path, route := range c.RateLimiter.Routes {
+		key := fmt.Sprintf("rate_limiter.routes.%s", path)
+		if route.Cost < 0 {
+			return fmt.Errorf("%s.cost can't be negative", key)
+		}
+		if route.MaxInFlight < 0 {
+			return fmt.Errorf("%s.max_in_flight can't be negative", key)
+		}
+		if err := validateFailMode(key+".fail_mode", route.FailMode); err != nil {
+			return err
+		}
+		if err := c.RateLimiter.Shedding.validateClass(key+".priority", route.Priority); err != nil {
+			return err
+		}
+		if route.Shadow != nil {
+			if err := route.Shadow.validate(key + ".shadow"); err != nil {
+				return err
+			}
+		}
+	}
+
+	for prefix, upstream := range c.Proxy.Upstreams {
+		key := fmt.Sprintf("proxy.upstreams.%s", prefix)
+		target, err := url.Parse(upstream.URL)
+		if err != nil || target.Scheme == "" || target.Host == "" {
+			return fmt.Errorf("%s.url %q is not an absolute URL", key, upstream.URL)
+		}
+		if upstream.Timeout < 0 {
+			return fmt.Errorf("%s.timeout_ms can't be negative", key)
+		}
+	}
+
+	if c.Check.Enabled() && !strings.HasPrefix(c.Check.Path, "/") {
+		return fmt.Errorf("check.path %q must start with /", c.Check.Path)
+	}
+	if c.Check.DeniedStatus != 0 && (c.Check.DeniedStatus < 400 || c.Check.DeniedStatus > 499) {
+		return fmt.Errorf("check.denied_status %d is not a client error status", c.Check.DeniedStatus)
+	}
+	switch c.Check.Proxy {
+	case "", CheckEnvoy:
+	case CheckNginx:
+		if denied := c.Check.Denied(); denied != 401 && denied != 403 {
+			return fmt.Errorf("check.denied_status must be 401 or 403 behind nginx, got %d", denied)
+		}
+	default:
+		return fmt.Errorf("check.proxy %q is unknown", c.Check.Proxy)
+	}
+
+	if c.RLS.Enabled() && c.RLS.Port == c.App.Port {
+		return errors.New("rls.port must differ from app.port")
+	}
+	for domain, descriptors := range c.RLS.Domains {
+		names := make(map[string]bool, len(descriptors))
+		for i, descriptor := range descriptors {
+			key := fmt.Sprintf("rls.domains.%s[%d]", domain, i)
+			if descriptor.Name == "" || names[descriptor.Name] {
+				return fmt.Errorf("%s.name must be set and unique in the domain", key)
+			}
+			names[descriptor.Name] = true
+			if len(descriptor.Entries) == 0 {
+				return fmt.Errorf("%s.entries can't be empty", key)
+			}
+			for _, entry := range descriptor.Entries {
+				if entry.Key == "" {
+					return fmt.Errorf("%s.entries key is required", key)
+				}
+			}
+			if err := descriptor.Policy.validate(key + ".policy"); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i, method := range c.Grpc.Methods {
+		key := fmt.Sprintf("grpc.methods[%d]", i)
+		if !strings.HasPrefix(method.Method, "/") || strings.Count(method.Method, "/") != 2 {
+			return fmt.Errorf("%s.method %q is not a full method name", key, method.Method)
+		}
+		if err := method.Policy.validate(key + ".policy"); err != nil {
+			return err
+		}
+	}
+
+	if c.Decisions.Enabled() && c.Decisions.Token == "" {
+		return errors.New("decisions.token is required with decisions.policies")
+	}
+	for name, policy := range c.Decisions.Policies {
+		if err := policy.validate(fmt.Sprintf("decisions.policies.%s", name)); err != nil {
+			return err
+		}
+	}
+
+	switch c.Tracing.Exporter {
+	case "", TracingOTLP, TracingStdout:
+	default:
+		return fmt.Errorf("tracing.exporter %q is unknown", c.Tracing.Exporter)
+	}
+
+	return nil
+}
+
+func (l LimitValues) validate(key string) error {
+	if l.MaxReq <= 0 {
+		return fmt.Errorf("%s.max_requests must be greater than zero", key)
+	}
+	if l.TimeWindow <= 0 {
+		return fmt.Errorf("%s.time_window must be greater than zero", key)
+	}
+	if l.BlockDuration <= 0 && len(l.BlockSchedule) == 0 {
+		return fmt.Errorf("%s.blocked_duration must be greater than zero", key)
+	}
+
+	return nil
+}
+
+func validateFailMode(key, mode string) error {
+	switch mode {
+	case "", FailOpen, FailClosed:
+		return nil
+	default:
+		return fmt.Errorf("%s %q is unknown", key, mode)
+	}
+}
+
+func (r Redis) validate() error {
+	switch r.Mode {
+	case "", RedisStandalone:
+	case RedisSentinel:
+		if r.MasterName == "" {
+			return errors.New("redis.master_name is required in sentinel mode")
+		}
+		if len(r.Addrs) == 0 {
+			return errors.New("redis.addrs is required in sentinel mode")
+		}
+	case RedisCluster:
+		if len(r.Addrs) == 0 {
+			return errors.New("redis.addrs is required in cluster mode")
+		}
+		if r.Db != 0 {
+			return errors.New("redis.db must be 0 in cluster mode")
+		}
+	default:
+		return fmt.Errorf("redis.mode %q is unknown", r.Mode)
+	}
+
+	if r.PoolSize < 0 || r.MinIdleConns < 0 {
+		return errors.New("redis pool sizes can't be negative")
+	}
+
+	return nil
+}
+
+func (a AdaptiveValues) validate() error {
+	if a.Interval < 0 {
+		return errors.New("rate_limiter.adaptive.interval can't be negative")
+	}
+	if !a.Enabled() {
+		return nil
+	}
+
+	if a.LatencyThreshold <= 0 && a.ErrorRatio <= 0 {
+		return errors.New("rate_limiter.adaptive needs latency_threshold_ms or error_ratio")
+	}
+	if a.LatencyThreshold < 0 || a.ErrorRatio < 0 || a.ErrorRatio > 1 {
+		return errors.New("rate_limiter.adaptive thresholds must be positive and error_ratio at most 1")
+	}
+	if a.Decrease < 0 || a.Decrease >= 1 {
+		return errors.New("rate_limiter.adaptive.decrease must be between 0 and 1")
+	}
+	if a.Increase < 0 || a.Increase > 1 || a.MinFactor < 0 || a.MinFactor > 1 {
+		return errors.New("rate_limiter.adaptive increase and min_factor must be between 0 and 1")
+	}
+	if a.MinSamples < 0 {
+		return errors.New("rate_limiter.adaptive.min_samples can't be negative")
+	}
+
+	return nil
+}
+
+func (s SheddingValues) validate() error {
+	if s.Capacity < 0 {
+		return errors.New("rate_limiter.shedding.capacity can't be negative")
+	}
+	if !s.Enabled() {
+		return nil
+	}
+
+	if len(s.Classes) == 0 {
+		return errors.New("rate_limiter.shedding.classes can't be empty")
+	}
+	for class, share := range s.Classes {
+		if share <= 0 || share > 1 {
+			return fmt.Errorf("rate_limiter.shedding.classes.%s must be greater than 0 and at most 1", class)
+		}
+	}
+	if err := s.validateClass("rate_limiter.shedding.anonymous_class", s.AnonymousClass); err != nil {
+		return err
+	}
+
+	return s.validateClass("rate_limiter.shedding.api_key_class", s.ApiKeyClass)
+}
+
+// validateClass checks that a priority names one of the classes, an empty priority is always valid
+func (s SheddingValues) validateClass(key, class string) error {
+	if class == "" || !s.Enabled() || s.Known(class) {
+		return nil
+	}
+
+	return fmt.Errorf("%s %q is not a shedding class", key, class)
+}
@@ -1,53 +1,245 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
+	"strings"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
 )
 
-const fileExtension = "json"
+// DefaultFileName is looked up in the working directory when no file is given, as env.json, env.yaml or env.toml
+const DefaultFileName = "env"
 
 type Viper struct {
-	fileName string
+	file     string
+	onReload []func(err error)
 }
 
-func NewViper(fileName string) *Viper {
+// NewViper reads the configuration from file, its format is taken from the extension: json, yaml or toml.
+// An empty file looks up DefaultFileName in the working directory
+func NewViper(file string) *Viper {
 	return &Viper{
-		fileName: fileName,
+		file: file,
 	}
 }
 
-func (v *Viper) ReadViper(config *Config) {
-	viper.SetConfigName(v.fileName)
-	viper.SetConfigType(fileExtension)
-	viper.AddConfigPath(".")
-	viper.WatchConfig()
+// ReadViper reads and validates the configuration file and keeps watching it. A changed file is read
+// into a new Config and swapped into the store only when it is valid, otherwise the last good one stays in use.
+// Every scalar key can be overridden by an environment variable, rate_limiter.by_ip.max_requests by RATE_LIMITER_BY_IP_MAX_REQUESTS.
+// The maps read with UnmarshalKey, such as rate_limiter.routes or proxy.upstreams, are only read from the file
+func (v *Viper) ReadViper() *Store {
+	if v.file == "" {
+		viper.SetConfigName(DefaultFileName)
+		viper.AddConfigPath(".")
+	} else {
+		viper.SetConfigFile(v.file)
+	}
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	viper.AutomaticEnv()
 
+	watch := true
 	if err := viper.ReadInConfig(); err != nil {
-		fmt.Println("fatal error config file: default \n", err)
+		var notFound viper.ConfigFileNotFoundError
+		if v.file != "" || !errors.As(err, &notFound) {
+			slog.Error("fatal error config file", "error", err)
+			os.Exit(1)
+		}
+		slog.Info("no config file found, reading the configuration from the environment")
+		watch = false
+	}
+
+	config, err := v.load()
+	if err != nil {
+		slog.Error("invalid config file", "error", err)
 		os.Exit(1)
 	}
 
-	v.readConfig(config)
+	store := NewStore(config)
+	if !watch {
+		return store
+	}
+
 	viper.WatchConfig()
 	viper.OnConfigChange(func(e fsnotify.Event) {
-		fmt.Println("Config file changed:", e.Name)
-		v.readConfig(config)
+		slog.Info("config file changed", "file", e.Name)
+		if err := v.reload(store); err != nil {
+			slog.Error("config reload rejected, keeping the last valid config", "file", e.Name, "error", err)
+		}
 	})
+
+	return store
+}
+
+// reload reads the configuration file again and swaps it into the store when it is valid,
+// the funcs registered with OnReload are told about the result either way
+func (v *Viper) reload(store *Store) error {
+	// viper keeps the previous values when the file can't be parsed, so it is read again to tell
+	var config *Config
+	err := viper.ReadInConfig()
+	if err == nil {
+		config, err = v.load()
+	}
+	if err == nil {
+		store.swap(config)
+	}
+	for _, f := range v.onReload {
+		f(err)
+	}
+
+	return err
+}
+
+func (v *Viper) load() (*Config, error) {
+	config := &Config{}
+	if err := v.readConfig(config); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
 }
 
-func (v *Viper) readConfig(c *Config) {
+// OnReload registers a func called every time the configuration file is reloaded,
+// it must be registered before the file changes
+func (v *Viper) OnReload(f func(err error)) {
+	v.onReload = append(v.onReload, f)
+}
+
+func (v *Viper) readConfig(c *Config) error {
+	c.Redis.Mode = viper.GetString("redis.mode")
 	c.Redis.Db = viper.GetInt("redis.db")
 	c.Redis.Host = viper.GetString("redis.host")
 	c.Redis.Port = viper.GetString("redis.port")
+	c.Redis.Addrs = viper.GetStringSlice("redis.addrs")
+	c.Redis.MasterName = viper.GetString("redis.master_name")
+	c.Redis.SentinelUsername = viper.GetString("redis.sentinel_username")
+	c.Redis.SentinelPassword = viper.GetString("redis.sentinel_password")
+	c.Redis.Username = viper.GetString("redis.username")
+	c.Redis.Password = viper.GetString("redis.password")
+	c.Redis.TLS = viper.GetBool("redis.tls")
+	c.Redis.InsecureSkipVerify = viper.GetBool("redis.insecure_skip_verify")
+	c.Redis.DialTimeout = viper.GetInt64("redis.dial_timeout_ms")
+	c.Redis.ReadTimeout = viper.GetInt64("redis.read_timeout_ms")
+	c.Redis.WriteTimeout = viper.GetInt64("redis.write_timeout_ms")
+	c.Redis.PoolSize = viper.GetInt("redis.pool_size")
+	c.Redis.MinIdleConns = viper.GetInt("redis.min_idle_conns")
+	c.Redis.PoolTimeout = viper.GetInt64("redis.pool_timeout_ms")
 
 	c.App.Host = viper.GetString("app.host")
 	c.App.Port = viper.GetString("app.port")
+	c.App.ReadTimeout = viper.GetInt64("app.read_timeout")
+	c.App.ReadHeaderTimeout = viper.GetInt64("app.read_header_timeout")
+	c.App.WriteTimeout = viper.GetInt64("app.write_timeout")
+	c.App.IdleTimeout = viper.GetInt64("app.idle_timeout")
+	c.App.ShutdownTimeout = viper.GetInt64("app.shutdown_timeout")
+
+	c.Admin.Token = viper.GetString("admin.token")
+
+	c.Log.Level = viper.GetString("log.level")
+	c.Log.Format = viper.GetString("log.format")
+	c.Log.Sampling.Initial = viper.GetInt("log.sampling.initial")
+	c.Log.Sampling.Thereafter = viper.GetInt("log.sampling.thereafter")
+
+	c.Health.LivenessPath = viper.GetString("health.liveness_path")
+	c.Health.ReadinessPath = viper.GetString("health.readiness_path")
+
+	c.Metrics.Path = viper.GetString("metrics.path")
+
+	c.Check.Path = viper.GetString("check.path")
+	c.Check.Proxy = viper.GetString("check.proxy")
+	c.Check.DeniedStatus = viper.GetInt("check.denied_status")
+
+	c.Tracing.Exporter = viper.GetString("tracing.exporter")
+	c.Tracing.Endpoint = viper.GetString("tracing.endpoint")
+	c.Tracing.Insecure = viper.GetBool("tracing.insecure")
+	c.Tracing.ServiceName = viper.GetString("tracing.service_name")
+	c.Tracing.SampleRatio = viper.GetFloat64("tracing.sample_ratio")
 
 	c.RateLimiter.ByIp.BlockDuration = viper.GetInt64("rate_limiter.by_ip.blocked_duration")
 	c.RateLimiter.ByIp.TimeWindow = viper.GetInt64("rate_limiter.by_ip.time_window")
 	c.RateLimiter.ByIp.MaxReq = viper.GetInt("rate_limiter.by_ip.max_requests")
+	c.RateLimiter.ByIp.BlockSchedule = getInt64Slice("rate_limiter.by_ip.block_schedule")
+	c.RateLimiter.ByIp.OffenseDecay = viper.GetInt64("rate_limiter.by_ip.offense_decay")
+	c.RateLimiter.ByIp.Shadow = viper.GetBool("rate_limiter.by_ip.shadow")
+
+	c.RateLimiter.FailMode = viper.GetString("rate_limiter.fail_mode")
+	c.RateLimiter.Breaker.FailureThreshold = viper.GetInt("rate_limiter.breaker.failure_threshold")
+	c.RateLimiter.Breaker.OpenTimeout = viper.GetInt64("rate_limiter.breaker.open_timeout")
+	c.RateLimiter.Breaker.Fallback = viper.GetBool("rate_limiter.breaker.fallback")
+
+	c.RateLimiter.Concurrency.ByIp = viper.GetInt("rate_limiter.concurrency.by_ip")
+	c.RateLimiter.Concurrency.ByApiKey = viper.GetInt("rate_limiter.concurrency.by_api_key")
+	c.RateLimiter.Concurrency.LeaseTTL = viper.GetInt64("rate_limiter.concurrency.lease_ttl")
+
+	c.RateLimiter.Adaptive.Interval = viper.GetInt64("rate_limiter.adaptive.interval")
+	c.RateLimiter.Adaptive.LatencyThreshold = viper.GetInt64("rate_limiter.adaptive.latency_threshold_ms")
+	c.RateLimiter.Adaptive.ErrorRatio = viper.GetFloat64("rate_limiter.adaptive.error_ratio")
+	c.RateLimiter.Adaptive.Decrease = viper.GetFloat64("rate_limiter.adaptive.decrease")
+	c.RateLimiter.Adaptive.Increase = viper.GetFloat64("rate_limiter.adaptive.increase")
+	c.RateLimiter.Adaptive.MinFactor = viper.GetFloat64("rate_limiter.adaptive.min_factor")
+	c.RateLimiter.Adaptive.MinSamples = viper.GetInt("rate_limiter.adaptive.min_samples")
+
+	c.RateLimiter.Shedding.Capacity = viper.GetInt("rate_limiter.shedding.capacity")
+	c.RateLimiter.Shedding.Header = viper.GetString("rate_limiter.shedding.header")
+	c.RateLimiter.Shedding.AnonymousClass = viper.GetString("rate_limiter.shedding.anonymous_class")
+	c.RateLimiter.Shedding.ApiKeyClass = viper.GetString("rate_limiter.shedding.api_key_class")
+	classes := make(map[string]float64)
+	if err := viper.UnmarshalKey("rate_limiter.shedding.classes", &classes); err != nil {
+		return fmt.Errorf("error reading rate_limiter.shedding.classes: %w", err)
+	}
+	c.RateLimiter.Shedding.Classes = classes
+
+	routes := make(map[string]RouteValues)
+	if err := viper.UnmarshalKey("rate_limiter.routes", &routes); err != nil {
+		return fmt.Errorf("error reading rate_limiter.routes: %w", err)
+	}
+	c.RateLimiter.Routes = routes
+
+	upstreams := make(map[string]UpstreamValues)
+	if err := viper.UnmarshalKey("proxy.upstreams", &upstreams); err != nil {
+		return fmt.Errorf("error reading proxy.upstreams: %w", err)
+	}
+	c.Proxy.Upstreams = upstreams
+
+	c.RLS.Port = viper.GetString("rls.port")
+	domains := make(map[string][]DescriptorValues)
+	if err := viper.UnmarshalKey("rls.domains", &domains); err != nil {
+		return fmt.Errorf("error reading rls.domains: %w", err)
+	}
+	c.RLS.Domains = domains
+
+	methods := make([]MethodValues, 0)
+	if err := viper.UnmarshalKey("grpc.methods", &methods); err != nil {
+		return fmt.Errorf("error reading grpc.methods: %w", err)
+	}
+	c.Grpc.Methods = methods
+
+	c.Decisions.Token = viper.GetString("decisions.token")
+	policies := make(map[string]LimitValues)
+	if err := viper.UnmarshalKey("decisions.policies", &policies); err != nil {
+		return fmt.Errorf("error reading decisions.policies: %w", err)
+	}
+	c.Decisions.Policies = policies
+
+	plans := make(map[string]PlanValues)
+	if err := viper.UnmarshalKey("rate_limiter.plans", &plans); err != nil {
+		return fmt.Errorf("error reading rate_limiter.plans: %w", err)
+	}
+	c.RateLimiter.Plans = plans
+
+	return nil
+}
+
+func getInt64Slice(key string) []int64 {
+	values := make([]int64, 0)
+	for _, v := range viper.GetIntSlice(key) {
+		values = append(values, int64(v))
+	}
+	return values
 }
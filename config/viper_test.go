@@ -0,0 +1,50 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, file, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(file, []byte(content), 0o600))
+}
+
+func TestReload(t *testing.T) {
+	t.Cleanup(viper.Reset)
+
+	file := filepath.Join(t.TempDir(), "env.json")
+	writeConfig(t, file, `{"app": {"port": "8080"}, "rate_limiter": {"by_ip": {"time_window": 1, "max_requests": 10, "blocked_duration": 60}}}`)
+	viper.SetConfigFile(file)
+	require.NoError(t, viper.ReadInConfig())
+
+	v := NewViper(file)
+	config, err := v.load()
+	require.NoError(t, err)
+	store := NewStore(config)
+
+	var reloads []error
+	v.OnReload(func(err error) { reloads = append(reloads, err) })
+
+	writeConfig(t, file, `{"app": {"port": "8080"}, "rate_limiter": {"by_ip": {"time_window": 1, "max_requests": 20, "blocked_duration": 60}}}`)
+	require.NoError(t, v.reload(store))
+	assert.Equal(t, 20, store.Current().RateLimiter.ByIp.MaxReq)
+
+	writeConfig(t, file, `{"app": {"port": "8080"}, "rate_limiter": {"by_ip": {"time_window": 1, "max_requests": 0, "blocked_duration": 60}}}`)
+	assert.Error(t, v.reload(store), "an invalid config is rejected")
+	assert.Equal(t, 20, store.Current().RateLimiter.ByIp.MaxReq, "the last valid config stays in use")
+
+	writeConfig(t, file, `{"app": {"port": `)
+	assert.Error(t, v.reload(store), "a file that can't be parsed is rejected")
+	assert.Equal(t, 20, store.Current().RateLimiter.ByIp.MaxReq)
+
+	require.Len(t, reloads, 3)
+	assert.NoError(t, reloads[0])
+	assert.Error(t, reloads[1])
+	assert.Error(t, reloads[2])
+}
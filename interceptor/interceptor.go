@@ -0,0 +1,306 @@
+package interceptor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/MatheusBenetti/rate-limiter/config"
+	"github.com/MatheusBenetti/rate-limiter/internal/dto"
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/database"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/logger"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/metrics"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/tracing"
+	"github.com/MatheusBenetti/rate-limiter/internal/usecase"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// strategy labels the decisions of the interceptors in the metrics and logs
+const strategy = "grpc"
+
+// Storage keeps the state of the interceptors, NewRedisStorage builds it
+type Storage interface {
+	ipRepository(namespace string) entity.IPRepository
+
+	apiKeyRepository() entity.ApiKeyRepository
+}
+
+type redisStorage struct {
+	client     redis.UniversalClient
+	cidrBlocks *database.CIDRBlocks
+}
+
+// NewRedisStorage keeps the state of the interceptors in redis, shared with the rate limiter service
+// and every other replica using the same redis
+func NewRedisStorage(client redis.UniversalClient) Storage {
+	return &redisStorage{client: client, cidrBlocks: database.NewCIDRBlocks(client, database.DefaultCIDRBlocksTTL)}
+}
+
+func (s *redisStorage) ipRepository(namespace string) entity.IPRepository {
+	return tracing.IPRepository(database.NewIPRedisWithNamespace(s.client, namespace).WithCIDRBlocks(s.cidrBlocks))
+}
+
+func (s *redisStorage) apiKeyRepository() entity.ApiKeyRepository {
+	return tracing.ApiKeyRepository(database.NewAPIKeyRedis(s.client))
+}
+
+// IdentityExtractor names the identity a call is limited by, an empty identity falls back to the
+// API key in the metadata or else to the peer address
+type IdentityExtractor func(ctx context.Context, fullMethod string) string
+
+// MetricsRecorder counts the decisions of the interceptors, the metrics of the rate limiter service implement it
+type MetricsRecorder interface {
+	Decision(strategy, policy, route, decision string)
+}
+
+// Option customizes the interceptors built by NewInterceptor
+type Option func(*Interceptor)
+
+// WithIdentity names the identity a call is limited by, by default the API key or else the peer address
+func WithIdentity(identity IdentityExtractor) Option {
+	return func(i *Interceptor) {
+		i.identity = identity
+	}
+}
+
+// WithMetrics counts every decision on recorder, by default the decisions are not counted
+func WithMetrics(recorder MetricsRecorder) Option {
+	return func(i *Interceptor) {
+		i.metrics = recorder
+	}
+}
+
+type noMetrics struct{}
+
+func (noMetrics) Decision(string, string, string, string) {}
+
+// Interceptor limits the calls of a gRPC server with the same use cases of the HTTP middleware.
+// A method with a policy of its own limits each identity by it, otherwise the API key sent in the
+// API_KEY metadata is limited by its plan and the remaining calls by the by_ip policy. An API key
+// that is not registered is rejected with codes.Unauthenticated
+type Interceptor struct {
+	storage  Storage
+	config   *config.Store
+	metrics  MetricsRecorder
+	identity IdentityExtractor
+}
+
+// NewInterceptor builds the interceptors limiting the calls by the configuration of store
+func NewInterceptor(storage Storage, store *config.Store, opts ...Option) *Interceptor {
+	i := &Interceptor{storage: storage, config: store, metrics: noMetrics{}}
+	for _, opt := range opts {
+		opt(i)
+	}
+	if i.metrics == nil {
+		i.metrics = noMetrics{}
+	}
+
+	return i
+}
+
+func (i *Interceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := i.limit(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// Stream limits the opening of a stream, the messages sent on it are not counted
+func (i *Interceptor) Stream() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := i.limit(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+
+		return handler(srv, ss)
+	}
+}
+
+// decision is the outcome of the use case that limited a call
+type decision struct {
+	allow      bool
+	retryAfter time.Duration
+	remaining  int
+}
+
+func (i *Interceptor) limit(ctx context.Context, fullMethod string) error {
+	cfg := i.config.Current()
+	apiKey := metadataValue(ctx, strings.ToLower(entity.ApiKeyHeader))
+	identity := ""
+	if i.identity != nil {
+		identity = i.identity(ctx, fullMethod)
+	}
+
+	route, policy := metrics.OtherRoute, "by_ip"
+	var limitFunc func(context.Context) (decision, error)
+	method, hasPolicy := cfg.Grpc.Method(fullMethod)
+	switch {
+	case hasPolicy:
+		route, policy = fullMethod, fullMethod
+		namespace := fmt.Sprintf("grpc:%s", fullMethod)
+		switch {
+		case identity != "":
+			limitFunc = i.byPolicy(identity, namespace, method.Policy)
+		case apiKey != "":
+			// the key names the identity only once it is known to be registered, so rotating made up
+			// keys can't get a fresh budget on every call
+			identity = fmt.Sprintf("api-key_%s", apiKey)
+			limitFunc = i.registered(apiKey, i.byPolicy(identity, namespace, method.Policy))
+		default:
+			identity = peerIP(ctx)
+			limitFunc = i.byPolicy(identity, namespace, method.Policy)
+		}
+	case identity == "" && apiKey != "":
+		policy, identity = "api_key", apiKey
+		limitFunc = i.byApiKey(cfg, apiKey)
+	default:
+		if identity == "" {
+			identity = peerIP(ctx)
+		}
+		limitFunc = i.byPolicy(identity, "", cfg.RateLimiter.ByIp)
+	}
+
+	ctx = logger.WithAttrs(ctx,
+		slog.String(logger.StrategyKey, strategy),
+		slog.String(logger.IdentityHashKey, logger.HashIdentity(identity)),
+	)
+	ctx, span := tracing.Tracer().Start(ctx, "rate_limiter.decision", trace.WithAttributes(
+		tracing.StrategyKey.String(strategy),
+		tracing.PolicyKey.String(policy),
+		tracing.RouteKey.String(route),
+	))
+	defer span.End()
+
+	result, err := limitFunc(ctx)
+	switch {
+	case errors.Is(err, entity.ErrIpAmountReq), errors.Is(err, entity.ErrApiKeyAmountReq):
+		i.metrics.Decision(strategy, policy, route, metrics.DecisionBlocked)
+		slog.InfoContext(ctx, "too many requests", logger.PolicyKey, policy, logger.DecisionKey, metrics.DecisionBlocked)
+		return exhausted(err.Error(), result.retryAfter)
+	case errors.Is(err, entity.ErrUnknownApiKey):
+		i.metrics.Decision(strategy, policy, route, metrics.DecisionLimited)
+		slog.InfoContext(ctx, "unknown api key", logger.PolicyKey, policy)
+		return status.Error(codes.Unauthenticated, err.Error())
+	case err != nil && !database.Unavailable(err):
+		// only a storage that could not be reached leaves the call to the fail mode
+		i.metrics.Decision(strategy, policy, route, metrics.DecisionLimited)
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "rate limiter rejected the call", logger.PolicyKey, policy, "error", err)
+		return status.Error(codes.Internal, err.Error())
+	case err != nil:
+		i.metrics.Decision(strategy, policy, route, metrics.DecisionUnavailable)
+		span.RecordError(err)
+		slog.WarnContext(ctx, "rate limiter could not decide on the call", logger.PolicyKey, policy, "error", err)
+		if cfg.RateLimiter.FailMode == config.FailOpen {
+			return nil
+		}
+		return status.Error(codes.Unavailable, entity.ErrBackendUnavailable.Error())
+	case !result.allow:
+		i.metrics.Decision(strategy, policy, route, metrics.DecisionLimited)
+		slog.InfoContext(ctx, "too many requests", logger.PolicyKey, policy, logger.DecisionKey, metrics.DecisionLimited)
+		return exhausted("too many requests", result.retryAfter)
+	}
+
+	i.metrics.Decision(strategy, policy, route, metrics.DecisionAllowed)
+	span.SetAttributes(tracing.AllowedKey.Bool(true), tracing.RemainingKey.Int(result.remaining))
+	return nil
+}
+
+func (i *Interceptor) byPolicy(identity, namespace string, limits config.LimitValues) func(context.Context) (decision, error) {
+	return func(ctx context.Context) (decision, error) {
+		ipReq := usecase.NewRegisterIPPolicyUseCase(i.storage.ipRepository(namespace), limits)
+		execute, err := ipReq.Execute(ctx, dto.IpReq{IP: identity, TimeAdded: time.Now(), Cost: 1})
+		if errors.Is(err, entity.ErrIpAmountReq) {
+			// the identity was blocked before, the policy tells for how long at most
+			return decision{retryAfter: time.Duration(limits.LongestBlock()) * time.Second}, err
+		}
+
+		return decision{allow: execute.Allow, retryAfter: execute.RetryAfter, remaining: execute.Remaining}, err
+	}
+}
+
+func (i *Interceptor) byApiKey(cfg *config.Config, apiKey string) func(context.Context) (decision, error) {
+	return func(ctx context.Context) (decision, error) {
+		tkReq := usecase.NewRegisterAPIKeyUseCase(i.storage.apiKeyRepository(), cfg)
+		execute, err := tkReq.Execute(ctx, dto.ApiKeyReq{Value: apiKey, TimeAdded: time.Now(), Cost: 1})
+		if errors.Is(err, entity.ErrApiKeyAmountReq) {
+			// the key was blocked before, its plan tells for how long at most. The call stays rejected
+			// without RetryInfo when the key can't be read
+			longest, blockErr := tkReq.LongestBlock(ctx, apiKey)
+			if blockErr != nil {
+				slog.WarnContext(ctx, "error reading the block of the api key", "error", blockErr)
+			}
+			return decision{retryAfter: longest}, err
+		}
+
+		return decision{allow: execute.Allow, retryAfter: execute.RetryAfter, remaining: execute.Remaining}, err
+	}
+}
+
+// registered runs next only for an API key that is registered
+func (i *Interceptor) registered(apiKey string, next func(context.Context) (decision, error)) func(context.Context) (decision, error) {
+	return func(ctx context.Context) (decision, error) {
+		if _, err := i.storage.apiKeyRepository().Get(ctx, apiKey); err != nil {
+			return decision{}, err
+		}
+
+		return next(ctx)
+	}
+}
+
+// exhausted rejects a call over the limit, the RetryInfo detail tells when to try again when it is known
+func exhausted(message string, retryAfter time.Duration) error {
+	st := status.New(codes.ResourceExhausted, message)
+	if retryAfter <= 0 {
+		return st.Err()
+	}
+
+	detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)})
+	if err != nil {
+		return st.Err()
+	}
+
+	return detailed.Err()
+}
+
+func metadataValue(ctx context.Context, key string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	ip, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+
+	return ip
+}
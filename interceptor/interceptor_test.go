@@ -0,0 +1,223 @@
+package interceptor
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/MatheusBenetti/rate-limiter/config"
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/database"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const checkMethod = "/grpc.health.v1.Health/Check"
+
+// newTestClient serves the health service behind the interceptors over an in-memory connection
+func newTestClient(
+	t *testing.T,
+	cfg *config.Config,
+	identity IdentityExtractor,
+) (healthpb.HealthClient, redis.UniversalClient, *miniredis.Miniredis) {
+	t.Helper()
+
+	redisServer := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: redisServer.Addr()})
+	limiter := NewInterceptor(NewRedisStorage(client), config.NewStore(cfg), WithIdentity(identity))
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(grpc.UnaryInterceptor(limiter.Unary()), grpc.StreamInterceptor(limiter.Stream()))
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return healthpb.NewHealthClient(conn), client, redisServer
+}
+
+func testConfig(maxReq int) *config.Config {
+	cfg := &config.Config{}
+	cfg.RateLimiter.ByIp = config.LimitValues{MaxReq: maxReq, TimeWindow: 60, BlockDuration: 60}
+	return cfg
+}
+
+func withApiKey(apiKey string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "api_key", apiKey)
+}
+
+func check(ctx context.Context, client healthpb.HealthClient) codes.Code {
+	_, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+	return status.Code(err)
+}
+
+func TestUnaryLimitsByPeer(t *testing.T) {
+	client, _, _ := newTestClient(t, testConfig(2), nil)
+
+	assert.Equal(t, codes.OK, check(context.Background(), client))
+	assert.Equal(t, codes.OK, check(context.Background(), client))
+
+	_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	st := status.Convert(err)
+	require.Equal(t, codes.ResourceExhausted, st.Code())
+	require.Len(t, st.Details(), 1)
+	retryInfo, ok := st.Details()[0].(*errdetails.RetryInfo)
+	require.True(t, ok)
+	assert.Positive(t, retryInfo.GetRetryDelay().AsDuration())
+}
+
+// retryDelay calls the client expecting the call to be over the limit, it returns the delay of its RetryInfo
+func retryDelay(t *testing.T, ctx context.Context, client healthpb.HealthClient) time.Duration {
+	t.Helper()
+
+	_, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+	st := status.Convert(err)
+	require.Equal(t, codes.ResourceExhausted, st.Code())
+	for _, detail := range st.Details() {
+		if retryInfo, ok := detail.(*errdetails.RetryInfo); ok {
+			return retryInfo.GetRetryDelay().AsDuration()
+		}
+	}
+
+	return 0
+}
+
+func TestBlockedCallsRetryInfo(t *testing.T) {
+	cfg := testConfig(1)
+	cfg.RateLimiter.ByIp.BlockSchedule = []int64{60, 600}
+	client, redisClient, _ := newTestClient(t, cfg, nil)
+
+	key := &entity.ApiKey{BlockDuration: 120, RateLimiter: entity.RateLimiter{MaxReq: 1, TimeWindow: 60}}
+	key.SetValue("registered")
+	_, err := database.NewAPIKeyRedis(redisClient).Save(context.Background(), key)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		ctx           context.Context
+		expectedDelay time.Duration
+	}{
+		{name: "blocked peer waits the longest step of the schedule", ctx: context.Background(), expectedDelay: 600 * time.Second},
+		{name: "blocked api key waits its block", ctx: withApiKey("registered"), expectedDelay: 120 * time.Second},
+	}
+
+	for i := 0; i < len(tests); i++ {
+		t.Run(tests[i].name, func(t *testing.T) {
+			require.Equal(t, codes.OK, check(tests[i].ctx, client))
+			require.Positive(t, retryDelay(t, tests[i].ctx, client), "the call going over the limit blocks")
+
+			assert.Equal(t, tests[i].expectedDelay, retryDelay(t, tests[i].ctx, client))
+		})
+	}
+}
+
+func TestStreamLimitsTheOpening(t *testing.T) {
+	client, _, _ := newTestClient(t, testConfig(1), nil)
+
+	watch := func() codes.Code {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		return status.Code(err)
+	}
+
+	assert.Equal(t, codes.OK, watch())
+	assert.Equal(t, codes.ResourceExhausted, watch())
+}
+
+func TestMethodPolicyIdentity(t *testing.T) {
+	cfg := testConfig(100)
+	cfg.Grpc.Methods = []config.MethodValues{{
+		Method: checkMethod,
+		Policy: config.LimitValues{MaxReq: 1, TimeWindow: 60, BlockDuration: 60},
+	}}
+	client, redisClient, _ := newTestClient(t, cfg, nil)
+
+	key := &entity.ApiKey{BlockDuration: 60, RateLimiter: entity.RateLimiter{MaxReq: 100, TimeWindow: 60}}
+	key.SetValue("registered")
+	_, err := database.NewAPIKeyRedis(redisClient).Save(context.Background(), key)
+	require.NoError(t, err)
+
+	assert.Equal(t, codes.Unauthenticated, check(withApiKey("made-up-1"), client))
+	assert.Equal(t, codes.Unauthenticated, check(withApiKey("made-up-2"), client))
+
+	assert.Equal(t, codes.OK, check(withApiKey("registered"), client))
+	assert.Equal(t, codes.ResourceExhausted, check(withApiKey("registered"), client))
+
+	assert.Equal(t, codes.OK, check(context.Background(), client), "the peer has a budget of its own")
+	assert.Equal(t, codes.ResourceExhausted, check(context.Background(), client))
+}
+
+func TestUnknownApiKeyIsUnauthenticated(t *testing.T) {
+	cfg := testConfig(100)
+	cfg.RateLimiter.FailMode = config.FailOpen
+	client, _, _ := newTestClient(t, cfg, nil)
+
+	assert.Equal(t, codes.Unauthenticated, check(withApiKey("made-up"), client))
+}
+
+func TestCustomIdentityExtractor(t *testing.T) {
+	cfg := testConfig(1)
+	cfg.Grpc.Methods = []config.MethodValues{{
+		Method: checkMethod,
+		Policy: config.LimitValues{MaxReq: 1, TimeWindow: 60, BlockDuration: 60},
+	}}
+	client, _, _ := newTestClient(t, cfg, func(ctx context.Context, _ string) string {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if users := md.Get("user"); len(users) > 0 {
+			return users[0]
+		}
+		return ""
+	})
+	asUser := func(user string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "user", user)
+	}
+
+	assert.Equal(t, codes.OK, check(asUser("alice"), client))
+	assert.Equal(t, codes.ResourceExhausted, check(asUser("alice"), client))
+	assert.Equal(t, codes.OK, check(asUser("bob"), client))
+}
+
+func TestFailMode(t *testing.T) {
+	tests := []struct {
+		failMode     string
+		expectedCode codes.Code
+	}{
+		{failMode: config.FailClosed, expectedCode: codes.Unavailable},
+		{failMode: config.FailOpen, expectedCode: codes.OK},
+	}
+
+	for i := 0; i < len(tests); i++ {
+		t.Run(tests[i].failMode, func(t *testing.T) {
+			cfg := testConfig(10)
+			cfg.RateLimiter.FailMode = tests[i].failMode
+			client, _, redisServer := newTestClient(t, cfg, nil)
+			redisServer.Close()
+
+			assert.Equal(t, tests[i].expectedCode, check(context.Background(), client))
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package dto
+
+type BlockInput struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Identity  string `json:"identity"`
+	Duration  int64  `json:"duration"`
+	Reason    string `json:"reason"`
+}
+
+type IdentityInput struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Identity  string `json:"identity"`
+}
+
+type BlockOutput struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Identity  string `json:"identity"`
+	Reason    string `json:"reason"`
+	TTL       int64  `json:"ttl"`
+}
+
+// AdaptiveOutput is the state of the adaptive limits after the last interval, P99 is in milliseconds
+type AdaptiveOutput struct {
+	Factor     float64 `json:"factor"`
+	ByIpLimit  int     `json:"by_ip_limit"`
+	Healthy    bool    `json:"healthy"`
+	Samples    int     `json:"samples"`
+	P99        int64   `json:"p99_ms"`
+	ErrorRatio float64 `json:"error_ratio"`
+}
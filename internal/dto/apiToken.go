@@ -6,17 +6,20 @@ type ApiKeyReqDb struct {
 	MaxReq     int     `json:"max_req"`
 	TimeWindow int64   `json:"time_window"`
 	Req        []int64 `json:"req"`
+	Cost       []int   `json:"cost,omitempty"`
 }
 
 type ApiKeyReq struct {
 	Value     string
 	TimeAdded time.Time
+	Cost      int
 }
 
 type Input struct {
-	MaxReq        int   `json:"max_req"`
-	TimeWindow    int64 `json:"time_window"`
-	BlockDuration int64 `json:"block_duration"`
+	MaxReq        int    `json:"max_req"`
+	TimeWindow    int64  `json:"time_window"`
+	BlockDuration int64  `json:"block_duration"`
+	Plan          string `json:"plan,omitempty"`
 }
 
 type Output struct {
@@ -24,5 +27,9 @@ type Output struct {
 }
 
 type ApiKeyAllow struct {
-	Allow bool
+	Allow      bool
+	RetryAfter time.Duration
+	Reset      time.Duration
+	Limit      int
+	Remaining  int
 }
@@ -0,0 +1,36 @@
+package dto
+
+// DescriptorInput asks whether Identity may spend Cost units of the budget of the Policy, an unset cost counts as one
+type DescriptorInput struct {
+	Policy   string `json:"policy"`
+	Identity string `json:"identity"`
+	Cost     int    `json:"cost"`
+}
+
+// CheckInput is the single descriptor of /v1/check. With Peek the state is reported without consuming quota
+type CheckInput struct {
+	DescriptorInput
+	Peek bool `json:"peek"`
+}
+
+type BatchCheckInput struct {
+	Descriptors []DescriptorInput `json:"descriptors"`
+	Peek        bool              `json:"peek"`
+}
+
+// DecisionOutput reports the decision and the quota of a descriptor, Reset and RetryAfter are in seconds
+type DecisionOutput struct {
+	Policy     string `json:"policy"`
+	Identity   string `json:"identity"`
+	Allowed    bool   `json:"allowed"`
+	Limit      int    `json:"limit"`
+	Remaining  int    `json:"remaining"`
+	Reset      int64  `json:"reset"`
+	RetryAfter int64  `json:"retry_after,omitempty"`
+}
+
+// BatchCheckOutput is allowed only when every descriptor is allowed
+type BatchCheckOutput struct {
+	Allowed     bool             `json:"allowed"`
+	Descriptors []DecisionOutput `json:"descriptors"`
+}
@@ -0,0 +1,13 @@
+package dto
+
+type HealthOutput struct {
+	Status   string          `json:"status"`
+	Config   string          `json:"config"`
+	Backends []BackendOutput `json:"backends"`
+}
+
+type BackendOutput struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+}
@@ -6,13 +6,32 @@ type IpReqDb struct {
 	MaxReq     int     `json:"max_req"`
 	TimeWindow int64   `json:"time_window"`
 	Req        []int64 `json:"req"`
+	Cost       []int   `json:"cost,omitempty"`
 }
 
 type IpReq struct {
 	IP        string
 	TimeAdded time.Time
+	Cost      int
 }
 
+// IpAllow tells whether a request was allowed, Reset is how long until the whole budget of the window is back
 type IpAllow struct {
-	Allow bool
+	Allow      bool
+	RetryAfter time.Duration
+	Reset      time.Duration
+	Limit      int
+	Remaining  int
+}
+
+type FailureReq struct {
+	Identity  string
+	TimeAdded time.Time
+	Status    int
+}
+
+type SlotReq struct {
+	Key      string
+	Limit    int
+	LeaseTTL time.Duration
 }
@@ -0,0 +1,61 @@
+package entity
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// AIMD adapts the factor the limits are scaled by: every unhealthy interval multiplies it by Decrease
+// and every healthy one adds Increase, it never goes below MinFactor nor above one
+type AIMD struct {
+	Decrease  float64
+	Increase  float64
+	MinFactor float64
+	factor    float64
+}
+
+func NewAIMD(decrease, increase, minFactor float64) *AIMD {
+	return &AIMD{
+		Decrease:  decrease,
+		Increase:  increase,
+		MinFactor: minFactor,
+		factor:    1,
+	}
+}
+
+// Adjust applies the outcome of an interval and returns the new factor
+func (a *AIMD) Adjust(healthy bool) float64 {
+	if healthy {
+		a.factor = math.Min(a.factor+a.Increase, 1)
+	} else {
+		a.factor = math.Max(a.factor*a.Decrease, a.MinFactor)
+	}
+
+	return a.factor
+}
+
+func (a *AIMD) Factor() float64 {
+	return a.factor
+}
+
+// ScaleLimit returns the limit scaled by factor, never less than one request. A factor
+// that is not between zero and one leaves the limit untouched
+func ScaleLimit(limit int, factor float64) int {
+	if factor <= 0 || factor >= 1 {
+		return limit
+	}
+
+	return max(int(math.Floor(float64(limit)*factor)), 1)
+}
+
+// Percentile returns the p-th percentile, between zero and one, of the latencies. It sorts them in place
+func Percentile(latencies []time.Duration, p float64) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	rank := int(math.Ceil(p*float64(len(latencies)))) - 1
+	return latencies[min(max(rank, 0), len(latencies)-1)]
+}
@@ -0,0 +1,40 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAIMDAdjust(t *testing.T) {
+	aimd := NewAIMD(0.5, 0.1, 0.2)
+
+	assert.Equal(t, 0.5, aimd.Adjust(false))
+	assert.Equal(t, 0.25, aimd.Adjust(false))
+	assert.Equal(t, 0.2, aimd.Adjust(false), "factor should not go below the minimum")
+	assert.InDelta(t, 0.3, aimd.Adjust(true), 1e-9)
+
+	for i := 0; i < 10; i++ {
+		aimd.Adjust(true)
+	}
+	assert.Equal(t, 1.0, aimd.Factor(), "factor should not go above one")
+}
+
+func TestScaleLimit(t *testing.T) {
+	assert.Equal(t, 10, ScaleLimit(10, 1))
+	assert.Equal(t, 10, ScaleLimit(10, 0))
+	assert.Equal(t, 5, ScaleLimit(10, 0.5))
+	assert.Equal(t, 1, ScaleLimit(3, 0.1), "limit should never reach zero")
+}
+
+func TestPercentile(t *testing.T) {
+	latencies := make([]time.Duration, 0, 100)
+	for i := 100; i > 0; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+
+	assert.Equal(t, 99*time.Millisecond, Percentile(latencies, 0.99))
+	assert.Equal(t, 50*time.Millisecond, Percentile(latencies, 0.5))
+	assert.Equal(t, time.Duration(0), Percentile(nil, 0.99))
+}
@@ -6,14 +6,17 @@ import (
 )
 
 const (
+	ApiKeyConfigKey     = "config:api-key"
 	ApiKeyRateKey       = "rate:api-key"
 	ApiKeyBlockDuration = "block:api-key"
+	ApiKeyOffenseKey    = "offense:api-key"
 	StatusApiKeyBlock   = "ApiKeyBlock"
 	ApiKeyHeader        = "API_KEY"
 )
 
 type ApiKey struct {
 	value         string
+	Plan          string
 	BlockDuration int64
 	RateLimiter   RateLimiter
 }
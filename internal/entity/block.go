@@ -0,0 +1,103 @@
+package entity
+
+import (
+	"net"
+	"time"
+)
+
+const (
+	BlockKindIP     = "ip"
+	BlockKindCIDR   = "cidr"
+	BlockKindApiKey = "api_key"
+
+	// the CIDR keys share a hash tag so they are updated together on redis cluster
+	CIDRBlockKey       = "{block:cidr}"
+	CIDRBlockReasonKey = "{block:cidr}:reason"
+	BlockReasonPrefix  = "block-reason"
+
+	// DefaultBlockReason describes the blocks applied by the limiter itself
+	DefaultBlockReason = "rate limit exceeded"
+
+	AdminTokenHeader = "X-Admin-Token"
+	// ApiTokenHeader carries the token of the callers of the decision API
+	ApiTokenHeader = "X-Api-Token"
+)
+
+// Block is an identity that is not allowed to perform requests until its TTL runs out. IP blocks may belong
+// to the namespace of another limiter, such as the failures of a route or a gRPC method, where the identity
+// is the one that limiter uses
+type Block struct {
+	Kind      string
+	Namespace string
+	Identity  string
+	Reason    string
+	TTL       time.Duration
+}
+
+// Normalize validates the block identity and rewrites it in its canonical form
+func (b *Block) Normalize() error {
+	if b.Namespace != "" {
+		if b.Kind != BlockKindIP {
+			return ErrInvalidNamespace
+		}
+		if b.Identity == "" {
+			return ErrInvalidIdentity
+		}
+		return nil
+	}
+
+	switch b.Kind {
+	case BlockKindIP:
+		ip := net.ParseIP(b.Identity)
+		if ip == nil {
+			return ErrInvalidIdentity
+		}
+		b.Identity = ip.String()
+	case BlockKindCIDR:
+		_, ipNet, err := net.ParseCIDR(b.Identity)
+		if err != nil {
+			return ErrInvalidIdentity
+		}
+		b.Identity = ipNet.String()
+	case BlockKindApiKey:
+		if b.Identity == "" {
+			return ErrInvalidIdentity
+		}
+	default:
+		return ErrInvalidBlockKind
+	}
+
+	return nil
+}
+
+func (b *Block) Validate() error {
+	if err := b.Normalize(); err != nil {
+		return err
+	}
+
+	if b.TTL <= 0 {
+		return ErrBlockTimeDuration
+	}
+
+	return nil
+}
+
+// CIDRContains reports whether ip falls inside any of the blocked ranges, returning the matching range
+func CIDRContains(ranges []string, ip string) (string, bool) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return "", false
+	}
+
+	for _, r := range ranges {
+		_, ipNet, err := net.ParseCIDR(r)
+		if err != nil {
+			continue
+		}
+		if ipNet.Contains(parsed) {
+			return r, true
+		}
+	}
+
+	return "", false
+}
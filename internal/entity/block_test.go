@@ -0,0 +1,79 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockValidate(t *testing.T) {
+	tests := []struct {
+		name             string
+		block            Block
+		expectedIdentity string
+		expectedErr      error
+	}{
+		{
+			name:             "ip",
+			block:            Block{Kind: BlockKindIP, Identity: "10.0.0.1", TTL: time.Minute},
+			expectedIdentity: "10.0.0.1",
+		},
+		{
+			name:             "cidr is normalized",
+			block:            Block{Kind: BlockKindCIDR, Identity: "10.0.3.7/16", TTL: time.Minute},
+			expectedIdentity: "10.0.0.0/16",
+		},
+		{
+			name:        "invalid ip",
+			block:       Block{Kind: BlockKindIP, Identity: "10.0.0", TTL: time.Minute},
+			expectedErr: ErrInvalidIdentity,
+		},
+		{
+			name:             "namespaced identity is kept as is",
+			block:            Block{Kind: BlockKindIP, Namespace: "failures/login", Identity: "user:42", TTL: time.Minute},
+			expectedIdentity: "user:42",
+		},
+		{
+			name:        "namespaced api key",
+			block:       Block{Kind: BlockKindApiKey, Namespace: "grpc:/auth.v1.AuthService/Login", Identity: "abc", TTL: time.Minute},
+			expectedErr: ErrInvalidNamespace,
+		},
+		{
+			name:        "unknown kind",
+			block:       Block{Kind: "user", Identity: "john", TTL: time.Minute},
+			expectedErr: ErrInvalidBlockKind,
+		},
+		{
+			name:        "no duration",
+			block:       Block{Kind: BlockKindApiKey, Identity: "abc"},
+			expectedErr: ErrBlockTimeDuration,
+		},
+	}
+
+	for i := 0; i < len(tests); i++ {
+		t.Run(tests[i].name, func(t *testing.T) {
+			err := tests[i].block.Validate()
+			if tests[i].expectedErr != nil {
+				assert.ErrorIs(t, err, tests[i].expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tests[i].expectedIdentity, tests[i].block.Identity)
+		})
+	}
+}
+
+func TestCIDRContains(t *testing.T) {
+	ranges := []string{"10.0.0.0/16", "2001:db8::/32"}
+
+	matched, ok := CIDRContains(ranges, "10.0.200.3")
+	assert.True(t, ok)
+	assert.Equal(t, "10.0.0.0/16", matched)
+
+	_, ok = CIDRContains(ranges, "2001:db8::1")
+	assert.True(t, ok)
+
+	_, ok = CIDRContains(ranges, "192.168.0.1")
+	assert.False(t, ok)
+}
@@ -3,9 +3,26 @@ package entity
 import "errors"
 
 var (
-	ErrIpAmountReq       = errors.New("you have reached the maximum number of Requests or actions by ip allowed within a certain time frame - blocked")
-	ErrApiKeyAmountReq   = errors.New("you have reached the maximum number of Requests or actions by api key allowed within a certain time frame - blocked")
-	ErrBlockTimeDuration = errors.New("blocked time duration should be greater than zero")
-	ErrTimeWindow        = errors.New("rate limiter time window duration should be greater than zero")
-	ErrRateLimiterMaxReq = errors.New("rate limiter maximum requests should be greater than zero")
+	ErrIpAmountReq         = errors.New("you have reached the maximum number of Requests or actions by ip allowed within a certain time frame - blocked")
+	ErrApiKeyAmountReq     = errors.New("you have reached the maximum number of Requests or actions by api key allowed within a certain time frame - blocked")
+	ErrBlockTimeDuration   = errors.New("blocked time duration should be greater than zero")
+	ErrTimeWindow          = errors.New("rate limiter time window duration should be greater than zero")
+	ErrRateLimiterMaxReq   = errors.New("rate limiter maximum requests should be greater than zero")
+	ErrTooManyFailures     = errors.New("you have reached the maximum number of failed attempts allowed within a certain time frame - blocked")
+	ErrConcurrencyLimit    = errors.New("you have reached the maximum number of simultaneous requests allowed")
+	ErrDelayExceeded       = errors.New("the next available slot is further away than the maximum delay allowed")
+	ErrQueueFull           = errors.New("too many requests are already waiting for the next available slot")
+	ErrUnknownPlan         = errors.New("api key plan is not configured")
+	ErrInvalidIdentity     = errors.New("identity is not valid for the block kind")
+	ErrInvalidBlockKind    = errors.New("block kind should be one of ip, cidr or api_key")
+	ErrInvalidNamespace    = errors.New("only ip blocks have a namespace")
+	ErrBlockNotFound       = errors.New("identity is not blocked")
+	ErrBackendUnavailable  = errors.New("rate limiter storage is unavailable")
+	ErrRequestCost         = errors.New("request cost should not be negative")
+	ErrUnknownPolicy       = errors.New("policy is not configured")
+	ErrInvalidDescriptor   = errors.New("descriptor should have a policy and an identity")
+	ErrDuplicateDescriptor = errors.New("descriptor is repeated in the batch")
+	ErrEmptyBatch          = errors.New("batch should have at least one descriptor")
+	ErrUnknownApiKey       = errors.New("api key is not registered")
+	ErrLoadShed            = errors.New("the service is saturated, try again later")
 )
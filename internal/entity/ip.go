@@ -3,6 +3,7 @@ package entity
 const (
 	IPPrefixRateKey          = "rate:ip"
 	IPPrefixBlockDurationKey = "block:ip"
+	IPPrefixOffenseKey       = "offense:ip"
 	StatusIPBlocked          = "IPBlocked"
 )
 
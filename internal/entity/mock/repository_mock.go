@@ -35,6 +35,20 @@ func (m *MockcommonRepository) EXPECT() *MockcommonRepositoryMockRecorder {
 	return m.recorder
 }
 
+// DeleteRequest mocks base method.
+func (m *MockcommonRepository) DeleteRequest(ctx context.Context, key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteRequest", ctx, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteRequest indicates an expected call of DeleteRequest.
+func (mr *MockcommonRepositoryMockRecorder) DeleteRequest(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRequest", reflect.TypeOf((*MockcommonRepository)(nil).DeleteRequest), ctx, key)
+}
+
 // GetBlockedDuration mocks base method.
 func (m *MockcommonRepository) GetBlockedDuration(ctx context.Context, key string) (string, error) {
 	m.ctrl.T.Helper()
@@ -65,6 +79,21 @@ func (mr *MockcommonRepositoryMockRecorder) GetRequest(ctx, key interface{}) *go
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRequest", reflect.TypeOf((*MockcommonRepository)(nil).GetRequest), ctx, key)
 }
 
+// IncrOffense mocks base method.
+func (m *MockcommonRepository) IncrOffense(ctx context.Context, key string, decay int64) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrOffense", ctx, key, decay)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IncrOffense indicates an expected call of IncrOffense.
+func (mr *MockcommonRepositoryMockRecorder) IncrOffense(ctx, key, decay interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrOffense", reflect.TypeOf((*MockcommonRepository)(nil).IncrOffense), ctx, key, decay)
+}
+
 // SaveBlockedDuration mocks base method.
 func (m *MockcommonRepository) SaveBlockedDuration(ctx context.Context, key string, BlockedDuration int64) error {
 	m.ctrl.T.Helper()
@@ -79,6 +108,20 @@ func (mr *MockcommonRepositoryMockRecorder) SaveBlockedDuration(ctx, key, Blocke
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveBlockedDuration", reflect.TypeOf((*MockcommonRepository)(nil).SaveBlockedDuration), ctx, key, BlockedDuration)
 }
 
+// UpdateRequest mocks base method.
+func (m *MockcommonRepository) UpdateRequest(ctx context.Context, key string, update func(*entity.RateLimiter) bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateRequest", ctx, key, update)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateRequest indicates an expected call of UpdateRequest.
+func (mr *MockcommonRepositoryMockRecorder) UpdateRequest(ctx, key, update interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateRequest", reflect.TypeOf((*MockcommonRepository)(nil).UpdateRequest), ctx, key, update)
+}
+
 // UpsertRequest mocks base method.
 func (m *MockcommonRepository) UpsertRequest(ctx context.Context, key string, rl *entity.RateLimiter) error {
 	m.ctrl.T.Helper()
@@ -116,6 +159,20 @@ func (m *MockApiKeyRepository) EXPECT() *MockApiKeyRepositoryMockRecorder {
 	return m.recorder
 }
 
+// DeleteRequest mocks base method.
+func (m *MockApiKeyRepository) DeleteRequest(ctx context.Context, key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteRequest", ctx, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteRequest indicates an expected call of DeleteRequest.
+func (mr *MockApiKeyRepositoryMockRecorder) DeleteRequest(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRequest", reflect.TypeOf((*MockApiKeyRepository)(nil).DeleteRequest), ctx, key)
+}
+
 // Get mocks base method.
 func (m *MockApiKeyRepository) Get(ctx context.Context, value string) (*entity.ApiKey, error) {
 	m.ctrl.T.Helper()
@@ -161,6 +218,21 @@ func (mr *MockApiKeyRepositoryMockRecorder) GetRequest(ctx, key interface{}) *go
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRequest", reflect.TypeOf((*MockApiKeyRepository)(nil).GetRequest), ctx, key)
 }
 
+// IncrOffense mocks base method.
+func (m *MockApiKeyRepository) IncrOffense(ctx context.Context, key string, decay int64) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrOffense", ctx, key, decay)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IncrOffense indicates an expected call of IncrOffense.
+func (mr *MockApiKeyRepositoryMockRecorder) IncrOffense(ctx, key, decay interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrOffense", reflect.TypeOf((*MockApiKeyRepository)(nil).IncrOffense), ctx, key, decay)
+}
+
 // Save mocks base method.
 func (m *MockApiKeyRepository) Save(ctx context.Context, key *entity.ApiKey) (string, error) {
 	m.ctrl.T.Helper()
@@ -190,6 +262,20 @@ func (mr *MockApiKeyRepositoryMockRecorder) SaveBlockedDuration(ctx, key, Blocke
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveBlockedDuration", reflect.TypeOf((*MockApiKeyRepository)(nil).SaveBlockedDuration), ctx, key, BlockedDuration)
 }
 
+// UpdateRequest mocks base method.
+func (m *MockApiKeyRepository) UpdateRequest(ctx context.Context, key string, update func(*entity.RateLimiter) bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateRequest", ctx, key, update)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateRequest indicates an expected call of UpdateRequest.
+func (mr *MockApiKeyRepositoryMockRecorder) UpdateRequest(ctx, key, update interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateRequest", reflect.TypeOf((*MockApiKeyRepository)(nil).UpdateRequest), ctx, key, update)
+}
+
 // UpsertRequest mocks base method.
 func (m *MockApiKeyRepository) UpsertRequest(ctx context.Context, key string, rl *entity.RateLimiter) error {
 	m.ctrl.T.Helper()
@@ -227,6 +313,20 @@ func (m *MockIPRepository) EXPECT() *MockIPRepositoryMockRecorder {
 	return m.recorder
 }
 
+// DeleteRequest mocks base method.
+func (m *MockIPRepository) DeleteRequest(ctx context.Context, key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteRequest", ctx, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteRequest indicates an expected call of DeleteRequest.
+func (mr *MockIPRepositoryMockRecorder) DeleteRequest(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRequest", reflect.TypeOf((*MockIPRepository)(nil).DeleteRequest), ctx, key)
+}
+
 // GetBlockedDuration mocks base method.
 func (m *MockIPRepository) GetBlockedDuration(ctx context.Context, key string) (string, error) {
 	m.ctrl.T.Helper()
@@ -257,6 +357,21 @@ func (mr *MockIPRepositoryMockRecorder) GetRequest(ctx, key interface{}) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRequest", reflect.TypeOf((*MockIPRepository)(nil).GetRequest), ctx, key)
 }
 
+// IncrOffense mocks base method.
+func (m *MockIPRepository) IncrOffense(ctx context.Context, key string, decay int64) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrOffense", ctx, key, decay)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IncrOffense indicates an expected call of IncrOffense.
+func (mr *MockIPRepositoryMockRecorder) IncrOffense(ctx, key, decay interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrOffense", reflect.TypeOf((*MockIPRepository)(nil).IncrOffense), ctx, key, decay)
+}
+
 // SaveBlockedDuration mocks base method.
 func (m *MockIPRepository) SaveBlockedDuration(ctx context.Context, key string, BlockedDuration int64) error {
 	m.ctrl.T.Helper()
@@ -271,6 +386,20 @@ func (mr *MockIPRepositoryMockRecorder) SaveBlockedDuration(ctx, key, BlockedDur
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveBlockedDuration", reflect.TypeOf((*MockIPRepository)(nil).SaveBlockedDuration), ctx, key, BlockedDuration)
 }
 
+// UpdateRequest mocks base method.
+func (m *MockIPRepository) UpdateRequest(ctx context.Context, key string, update func(*entity.RateLimiter) bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateRequest", ctx, key, update)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateRequest indicates an expected call of UpdateRequest.
+func (mr *MockIPRepositoryMockRecorder) UpdateRequest(ctx, key, update interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateRequest", reflect.TypeOf((*MockIPRepository)(nil).UpdateRequest), ctx, key, update)
+}
+
 // UpsertRequest mocks base method.
 func (m *MockIPRepository) UpsertRequest(ctx context.Context, key string, rl *entity.RateLimiter) error {
 	m.ctrl.T.Helper()
@@ -284,3 +413,188 @@ func (mr *MockIPRepositoryMockRecorder) UpsertRequest(ctx, key, rl interface{})
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertRequest", reflect.TypeOf((*MockIPRepository)(nil).UpsertRequest), ctx, key, rl)
 }
+
+// MockSemaphoreRepository is a mock of SemaphoreRepository interface.
+type MockSemaphoreRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockSemaphoreRepositoryMockRecorder
+}
+
+// MockSemaphoreRepositoryMockRecorder is the mock recorder for MockSemaphoreRepository.
+type MockSemaphoreRepositoryMockRecorder struct {
+	mock *MockSemaphoreRepository
+}
+
+// NewMockSemaphoreRepository creates a new mock instance.
+func NewMockSemaphoreRepository(ctrl *gomock.Controller) *MockSemaphoreRepository {
+	mock := &MockSemaphoreRepository{ctrl: ctrl}
+	mock.recorder = &MockSemaphoreRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSemaphoreRepository) EXPECT() *MockSemaphoreRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Acquire mocks base method.
+func (m *MockSemaphoreRepository) Acquire(ctx context.Context, lease *entity.Lease, limit int) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Acquire", ctx, lease, limit)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Acquire indicates an expected call of Acquire.
+func (mr *MockSemaphoreRepositoryMockRecorder) Acquire(ctx, lease, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Acquire", reflect.TypeOf((*MockSemaphoreRepository)(nil).Acquire), ctx, lease, limit)
+}
+
+// Refresh mocks base method.
+func (m *MockSemaphoreRepository) Refresh(ctx context.Context, lease *entity.Lease) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Refresh", ctx, lease)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Refresh indicates an expected call of Refresh.
+func (mr *MockSemaphoreRepositoryMockRecorder) Refresh(ctx, lease interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Refresh", reflect.TypeOf((*MockSemaphoreRepository)(nil).Refresh), ctx, lease)
+}
+
+// Release mocks base method.
+func (m *MockSemaphoreRepository) Release(ctx context.Context, lease *entity.Lease) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Release", ctx, lease)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Release indicates an expected call of Release.
+func (mr *MockSemaphoreRepositoryMockRecorder) Release(ctx, lease interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Release", reflect.TypeOf((*MockSemaphoreRepository)(nil).Release), ctx, lease)
+}
+
+// MockAdminRepository is a mock of AdminRepository interface.
+type MockAdminRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockAdminRepositoryMockRecorder
+}
+
+// MockAdminRepositoryMockRecorder is the mock recorder for MockAdminRepository.
+type MockAdminRepositoryMockRecorder struct {
+	mock *MockAdminRepository
+}
+
+// NewMockAdminRepository creates a new mock instance.
+func NewMockAdminRepository(ctrl *gomock.Controller) *MockAdminRepository {
+	mock := &MockAdminRepository{ctrl: ctrl}
+	mock.recorder = &MockAdminRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAdminRepository) EXPECT() *MockAdminRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Block mocks base method.
+func (m *MockAdminRepository) Block(ctx context.Context, block *entity.Block) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Block", ctx, block)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Block indicates an expected call of Block.
+func (mr *MockAdminRepositoryMockRecorder) Block(ctx, block interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Block", reflect.TypeOf((*MockAdminRepository)(nil).Block), ctx, block)
+}
+
+// ListBlocked mocks base method.
+func (m *MockAdminRepository) ListBlocked(ctx context.Context) ([]entity.Block, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListBlocked", ctx)
+	ret0, _ := ret[0].([]entity.Block)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListBlocked indicates an expected call of ListBlocked.
+func (mr *MockAdminRepositoryMockRecorder) ListBlocked(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListBlocked", reflect.TypeOf((*MockAdminRepository)(nil).ListBlocked), ctx)
+}
+
+// Reset mocks base method.
+func (m *MockAdminRepository) Reset(ctx context.Context, kind, namespace, identity string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reset", ctx, kind, namespace, identity)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Reset indicates an expected call of Reset.
+func (mr *MockAdminRepositoryMockRecorder) Reset(ctx, kind, namespace, identity interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reset", reflect.TypeOf((*MockAdminRepository)(nil).Reset), ctx, kind, namespace, identity)
+}
+
+// Unblock mocks base method.
+func (m *MockAdminRepository) Unblock(ctx context.Context, kind, namespace, identity string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Unblock", ctx, kind, namespace, identity)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Unblock indicates an expected call of Unblock.
+func (mr *MockAdminRepositoryMockRecorder) Unblock(ctx, kind, namespace, identity interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unblock", reflect.TypeOf((*MockAdminRepository)(nil).Unblock), ctx, kind, namespace, identity)
+}
+
+// MockHealthRepository is a mock of HealthRepository interface.
+type MockHealthRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockHealthRepositoryMockRecorder
+}
+
+// MockHealthRepositoryMockRecorder is the mock recorder for MockHealthRepository.
+type MockHealthRepositoryMockRecorder struct {
+	mock *MockHealthRepository
+}
+
+// NewMockHealthRepository creates a new mock instance.
+func NewMockHealthRepository(ctrl *gomock.Controller) *MockHealthRepository {
+	mock := &MockHealthRepository{ctrl: ctrl}
+	mock.recorder = &MockHealthRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockHealthRepository) EXPECT() *MockHealthRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Ping mocks base method.
+func (m *MockHealthRepository) Ping(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockHealthRepositoryMockRecorder) Ping(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockHealthRepository)(nil).Ping), ctx)
+}
@@ -0,0 +1,66 @@
+package entity
+
+// DefaultOffenseDecaySeconds is how long an offense is remembered when the policy does not say otherwise
+const DefaultOffenseDecaySeconds = 24 * 60 * 60
+
+// Penalty decides how long an identity stays blocked. Without a schedule every block lasts BlockDuration,
+// with one the nth offense inside the decay period is blocked for the nth step
+type Penalty struct {
+	BlockDuration int64
+	Schedule      []int64
+	Decay         int64
+}
+
+func (p Penalty) Progressive() bool {
+	return len(p.Schedule) > 0
+}
+
+// DurationFor returns the block duration of the given offense, counting from one.
+// The last step of the schedule repeats once it is exhausted
+func (p Penalty) DurationFor(offense int64) int64 {
+	if !p.Progressive() {
+		return p.BlockDuration
+	}
+
+	if offense < 1 {
+		offense = 1
+	}
+	if offense > int64(len(p.Schedule)) {
+		offense = int64(len(p.Schedule))
+	}
+
+	return p.Schedule[offense-1]
+}
+
+// Longest returns the longest block the penalty gives
+func (p Penalty) Longest() int64 {
+	longest := p.BlockDuration
+	for _, step := range p.Schedule {
+		longest = max(longest, step)
+	}
+
+	return longest
+}
+
+// DecayDuration returns how long an offense is remembered after it happened
+func (p Penalty) DecayDuration() int64 {
+	if p.Decay > 0 {
+		return p.Decay
+	}
+
+	return DefaultOffenseDecaySeconds
+}
+
+func (p Penalty) Validate() error {
+	if !p.Progressive() && p.BlockDuration == 0 {
+		return ErrBlockTimeDuration
+	}
+
+	for _, step := range p.Schedule {
+		if step <= 0 {
+			return ErrBlockTimeDuration
+		}
+	}
+
+	return nil
+}
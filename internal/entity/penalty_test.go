@@ -0,0 +1,54 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPenaltyDurationFor(t *testing.T) {
+	tests := []struct {
+		name             string
+		penalty          Penalty
+		offense          int64
+		expectedDuration int64
+	}{
+		{
+			name:             "fixed duration",
+			penalty:          Penalty{BlockDuration: 60},
+			offense:          3,
+			expectedDuration: 60,
+		},
+		{
+			name:             "first offense",
+			penalty:          Penalty{BlockDuration: 60, Schedule: []int64{60, 300, 3600}},
+			offense:          1,
+			expectedDuration: 60,
+		},
+		{
+			name:             "second offense",
+			penalty:          Penalty{BlockDuration: 60, Schedule: []int64{60, 300, 3600}},
+			offense:          2,
+			expectedDuration: 300,
+		},
+		{
+			name:             "schedule exhausted",
+			penalty:          Penalty{BlockDuration: 60, Schedule: []int64{60, 300, 3600}},
+			offense:          7,
+			expectedDuration: 3600,
+		},
+	}
+
+	for i := 0; i < len(tests); i++ {
+		t.Run(tests[i].name, func(t *testing.T) {
+			assert.Equal(t, tests[i].expectedDuration, tests[i].penalty.DurationFor(tests[i].offense))
+		})
+	}
+}
+
+func TestPenaltyValidate(t *testing.T) {
+	assert.NoError(t, Penalty{BlockDuration: 60}.Validate())
+	assert.NoError(t, Penalty{Schedule: []int64{60, 300}}.Validate())
+	assert.ErrorIs(t, Penalty{}.Validate(), ErrBlockTimeDuration)
+	assert.ErrorIs(t, Penalty{Schedule: []int64{60, 0}}.Validate(), ErrBlockTimeDuration)
+}
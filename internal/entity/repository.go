@@ -8,11 +8,19 @@ import (
 type commonRepository interface {
 	UpsertRequest(ctx context.Context, key string, rl *RateLimiter) error
 
+	// UpdateRequest reads, changes and stores the requests of the key as a single operation, update may run
+	// more than once when another client changed the key meanwhile and nothing is stored when it returns false
+	UpdateRequest(ctx context.Context, key string, update func(rl *RateLimiter) bool) error
+
 	SaveBlockedDuration(ctx context.Context, key string, BlockedDuration int64) error
 
+	IncrOffense(ctx context.Context, key string, decay int64) (int64, error)
+
 	GetBlockedDuration(ctx context.Context, key string) (string, error)
 
 	GetRequest(ctx context.Context, key string) (*RateLimiter, error)
+
+	DeleteRequest(ctx context.Context, key string) error
 }
 
 type ApiKeyRepository interface {
@@ -26,3 +34,25 @@ type ApiKeyRepository interface {
 type IPRepository interface {
 	commonRepository
 }
+
+type SemaphoreRepository interface {
+	Acquire(ctx context.Context, lease *Lease, limit int) (bool, error)
+
+	Refresh(ctx context.Context, lease *Lease) (bool, error)
+
+	Release(ctx context.Context, lease *Lease) error
+}
+
+type AdminRepository interface {
+	Block(ctx context.Context, block *Block) error
+
+	Unblock(ctx context.Context, kind, namespace, identity string) (bool, error)
+
+	Reset(ctx context.Context, kind, namespace, identity string) error
+
+	ListBlocked(ctx context.Context) ([]Block, error)
+}
+
+type HealthRepository interface {
+	Ping(ctx context.Context) error
+}
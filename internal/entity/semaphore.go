@@ -0,0 +1,44 @@
+package entity
+
+import (
+	"encoding/hex"
+	"time"
+)
+
+const (
+	SemaphorePrefixKey     = "inflight"
+	DefaultLeaseTTLSeconds = 30
+)
+
+// Lease is a slot held in a distributed semaphore while a request is in flight
+type Lease struct {
+	id  string
+	Key string
+	TTL time.Duration
+}
+
+func NewLease(key string, ttl time.Duration) (*Lease, error) {
+	bytes, err := generateRandomBytes(16)
+	if err != nil {
+		return nil, err
+	}
+
+	if ttl <= 0 {
+		ttl = DefaultLeaseTTLSeconds * time.Second
+	}
+
+	return &Lease{
+		id:  hex.EncodeToString(bytes),
+		Key: key,
+		TTL: ttl,
+	}, nil
+}
+
+func (l *Lease) ID() string {
+	return l.id
+}
+
+// RefreshInterval is how often the holder should extend the lease so it never expires mid-request
+func (l *Lease) RefreshInterval() time.Duration {
+	return l.TTL / 3
+}
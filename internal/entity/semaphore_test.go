@@ -0,0 +1,21 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLease(t *testing.T) {
+	lease, err := NewLease("inflight:ip_127.0.0.1", 0)
+	require.NoError(t, err)
+	require.Len(t, lease.ID(), 32)
+	require.Equal(t, DefaultLeaseTTLSeconds*time.Second, lease.TTL)
+	require.Equal(t, 10*time.Second, lease.RefreshInterval())
+
+	other, err := NewLease("inflight:ip_127.0.0.1", 3*time.Second)
+	require.NoError(t, err)
+	require.NotEqual(t, lease.ID(), other.ID())
+	require.Equal(t, 3*time.Second, other.TTL)
+}
@@ -5,19 +5,66 @@ import (
 	"time"
 )
 
+// CostHeader is the response header a handler can set to report the cost of the request it served
+const CostHeader = "X-RateLimit-Cost"
+
+// LimitHeader and RemainingHeader tell the client the budget of its time window and how much of it is left
+const (
+	LimitHeader     = "X-RateLimit-Limit"
+	RemainingHeader = "X-RateLimit-Remaining"
+)
+
 type RateLimiter struct {
 	Req        []time.Time
+	Cost       []int
 	TimeWindow int64
 	MaxReq     int
 	lock       sync.Mutex
 }
 
+// Allow reports whether the accumulated cost inside the time window fits the MaxReq budget
 func (rl *RateLimiter) Allow(fromTime time.Time) bool {
 	rl.lock.Lock()
 	defer rl.lock.Unlock()
 
 	rl.removeOldReq(fromTime)
-	return len(rl.Req) <= rl.MaxReq
+	return rl.usedCost() <= rl.MaxReq
+}
+
+// Remaining returns how much of the MaxReq budget is still available inside the time window,
+// a negative value means the budget was exceeded
+func (rl *RateLimiter) Remaining(fromTime time.Time) int {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+
+	rl.removeOldReq(fromTime)
+	return rl.MaxReq - rl.usedCost()
+}
+
+// NextSlot returns how long until a request of the given cost fits the budget, zero when it fits now.
+// It returns false when the cost is larger than the whole budget and will never fit
+func (rl *RateLimiter) NextSlot(fromTime time.Time, cost int) (time.Duration, bool) {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+
+	if cost > rl.MaxReq {
+		return 0, false
+	}
+
+	rl.removeOldReq(fromTime)
+	excess := rl.usedCost() + cost - rl.MaxReq
+	if excess <= 0 {
+		return 0, true
+	}
+
+	for i, t := range rl.Req {
+		excess -= rl.costAt(i)
+		if excess <= 0 {
+			return t.Add(rl.GetDurationTimeWindow()).Sub(fromTime), true
+		}
+	}
+
+	return rl.GetDurationTimeWindow(), true
 }
 
 func (rl *RateLimiter) GetDurationTimeWindow() time.Duration {
@@ -26,7 +73,7 @@ func (rl *RateLimiter) GetDurationTimeWindow() time.Duration {
 
 func (rl *RateLimiter) removeOldReq(fromTime time.Time) {
 	threshold := fromTime.Add(-rl.GetDurationTimeWindow())
-	start := 0
+	start := len(rl.Req)
 	for i, t := range rl.Req {
 		if t.After(threshold) {
 			start = i
@@ -34,10 +81,60 @@ func (rl *RateLimiter) removeOldReq(fromTime time.Time) {
 		}
 	}
 	rl.Req = rl.Req[start:]
+	if start < len(rl.Cost) {
+		rl.Cost = rl.Cost[start:]
+	} else {
+		rl.Cost = nil
+	}
+}
+
+// usedCost sums the cost of the stored requests, entries without a cost count as one
+func (rl *RateLimiter) usedCost() int {
+	used := 0
+	for i := range rl.Req {
+		used += rl.costAt(i)
+	}
+	return used
+}
+
+func (rl *RateLimiter) costAt(i int) int {
+	if i < len(rl.Cost) {
+		return rl.Cost[i]
+	}
+	return 1
 }
 
 func (rl *RateLimiter) AddReq(request time.Time) {
+	rl.AddWeightedReq(request, 1)
+}
+
+// AddWeightedReq stores a request that consumes cost units of the budget
+func (rl *RateLimiter) AddWeightedReq(request time.Time, cost int) {
+	for len(rl.Cost) < len(rl.Req) {
+		rl.Cost = append(rl.Cost, 1)
+	}
 	rl.Req = append(rl.Req, request)
+	rl.Cost = append(rl.Cost, cost)
+}
+
+// RemoveWeightedReq drops the latest stored request made at the same second with the same cost,
+// it returns false when there is no such request
+func (rl *RateLimiter) RemoveWeightedReq(request time.Time, cost int) bool {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+
+	for len(rl.Cost) < len(rl.Req) {
+		rl.Cost = append(rl.Cost, 1)
+	}
+	for i := len(rl.Req) - 1; i >= 0; i-- {
+		if rl.Req[i].Unix() == request.Unix() && rl.Cost[i] == cost {
+			rl.Req = append(rl.Req[:i], rl.Req[i+1:]...)
+			rl.Cost = append(rl.Cost[:i], rl.Cost[i+1:]...)
+			return true
+		}
+	}
+
+	return false
 }
 
 func (rl *RateLimiter) Validate() error {
@@ -55,6 +55,19 @@ func TestRemoveOldRequests(t *testing.T) {
 			},
 			expectedLen: 1,
 		},
+		{
+			name: "All Requests to remove",
+			rl: RateLimiter{
+				Req: []time.Time{
+					startTime.Add(-3 * time.Second),
+					startTime.Add(-2 * time.Second),
+				},
+				Cost:       []int{1, 5},
+				TimeWindow: 1,
+				MaxReq:     10,
+			},
+			expectedLen: 0,
+		},
 	}
 
 	for i := 0; i < len(tests); i++ {
@@ -177,3 +190,154 @@ func TestAllow(t *testing.T) {
 		})
 	}
 }
+
+func TestAllowWeighted(t *testing.T) {
+	startTime := time.Date(2024, time.January, 1, 12, 34, 56, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		costs         []int
+		maxReq        int
+		expectedAllow bool
+	}{
+		{
+			name:          "allow within budget",
+			costs:         []int{1, 1, 5},
+			maxReq:        10,
+			expectedAllow: true,
+		},
+		{
+			name:          "allow exactly the budget",
+			costs:         []int{5, 5},
+			maxReq:        10,
+			expectedAllow: true,
+		},
+		{
+			name:          "no allow single expensive request",
+			costs:         []int{50},
+			maxReq:        10,
+			expectedAllow: false,
+		},
+	}
+
+	for i := 0; i < len(tests); i++ {
+		t.Run(tests[i].name, func(t *testing.T) {
+			rl := RateLimiter{TimeWindow: 1, MaxReq: tests[i].maxReq}
+			for _, cost := range tests[i].costs {
+				rl.AddWeightedReq(startTime, cost)
+			}
+			assert.Equal(t, tests[i].expectedAllow, rl.Allow(startTime))
+		})
+	}
+}
+
+func TestAddWeightedReqKeepsLegacyEntries(t *testing.T) {
+	startTime := time.Date(2024, time.January, 1, 12, 34, 56, 0, time.UTC)
+	rl := RateLimiter{
+		Req:        []time.Time{startTime, startTime},
+		TimeWindow: 1,
+		MaxReq:     10,
+	}
+
+	rl.AddWeightedReq(startTime, 8)
+
+	assert.Equal(t, []int{1, 1, 8}, rl.Cost)
+	assert.True(t, rl.Allow(startTime))
+	rl.AddReq(startTime)
+	assert.False(t, rl.Allow(startTime))
+}
+
+func TestRemaining(t *testing.T) {
+	startTime := time.Date(2024, time.January, 1, 12, 34, 56, 0, time.UTC)
+	rl := RateLimiter{TimeWindow: 1, MaxReq: 3}
+
+	assert.Equal(t, 3, rl.Remaining(startTime))
+	rl.AddReq(startTime)
+	rl.AddWeightedReq(startTime, 2)
+	assert.Equal(t, 0, rl.Remaining(startTime))
+	rl.AddReq(startTime)
+	assert.Equal(t, -1, rl.Remaining(startTime))
+}
+
+func TestNextSlot(t *testing.T) {
+	startTime := time.Date(2024, time.January, 1, 12, 34, 56, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		costs        []int
+		offsets      []time.Duration
+		cost         int
+		expectedWait time.Duration
+		expectedOk   bool
+	}{
+		{
+			name:         "fits now",
+			costs:        []int{1, 1},
+			offsets:      []time.Duration{-500 * time.Millisecond, -100 * time.Millisecond},
+			cost:         1,
+			expectedWait: 0,
+			expectedOk:   true,
+		},
+		{
+			name:         "waits for the oldest request",
+			costs:        []int{2, 1},
+			offsets:      []time.Duration{-800 * time.Millisecond, -100 * time.Millisecond},
+			cost:         1,
+			expectedWait: 200 * time.Millisecond,
+			expectedOk:   true,
+		},
+		{
+			name:         "waits for two requests",
+			costs:        []int{1, 1, 1},
+			offsets:      []time.Duration{-800 * time.Millisecond, -500 * time.Millisecond, -100 * time.Millisecond},
+			cost:         2,
+			expectedWait: 500 * time.Millisecond,
+			expectedOk:   true,
+		},
+		{
+			name:       "never fits",
+			cost:       4,
+			expectedOk: false,
+		},
+	}
+
+	for i := 0; i < len(tests); i++ {
+		t.Run(tests[i].name, func(t *testing.T) {
+			rl := RateLimiter{TimeWindow: 1, MaxReq: 3}
+			for j, cost := range tests[i].costs {
+				rl.AddWeightedReq(startTime.Add(tests[i].offsets[j]), cost)
+			}
+
+			wait, ok := rl.NextSlot(startTime, tests[i].cost)
+			assert.Equal(t, tests[i].expectedOk, ok)
+			assert.Equal(t, tests[i].expectedWait, wait)
+		})
+	}
+}
+
+func TestRemainingAfterTheWholeWindowExpired(t *testing.T) {
+	startTime := time.Date(2024, time.January, 1, 12, 34, 56, 0, time.UTC)
+	rl := RateLimiter{TimeWindow: 1, MaxReq: 3}
+	rl.AddWeightedReq(startTime.Add(-5*time.Second), 2)
+	rl.AddReq(startTime.Add(-4 * time.Second))
+
+	assert.Equal(t, 3, rl.Remaining(startTime))
+	assert.Empty(t, rl.Req)
+	assert.Empty(t, rl.Cost)
+}
+
+func TestRemoveWeightedReq(t *testing.T) {
+	startTime := time.Date(2024, time.January, 1, 12, 34, 56, 0, time.UTC)
+	rl := RateLimiter{TimeWindow: 60, MaxReq: 10}
+	rl.Req = []time.Time{startTime}
+	rl.AddWeightedReq(startTime, 3)
+	rl.AddWeightedReq(startTime.Add(time.Second), 3)
+
+	assert.True(t, rl.RemoveWeightedReq(startTime.Add(500*time.Millisecond), 3), "requests are matched by the second")
+	assert.Equal(t, []time.Time{startTime, startTime.Add(time.Second)}, rl.Req)
+	assert.Equal(t, []int{1, 3}, rl.Cost)
+
+	assert.False(t, rl.RemoveWeightedReq(startTime, 3))
+	assert.True(t, rl.RemoveWeightedReq(startTime, 1), "entries without a cost count as one")
+	assert.Equal(t, []int{3}, rl.Cost)
+}
@@ -0,0 +1,244 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/redis/go-redis/v9"
+)
+
+type AdminRedis struct {
+	redisCli redis.UniversalClient
+}
+
+func NewAdminRedis(redisCli redis.UniversalClient) *AdminRedis {
+	return &AdminRedis{redisCli: redisCli}
+}
+
+// Block stores a manual block, IP and API key blocks share the keys written by the limiter. An IP block
+// with a namespace is stored in the keys of the limiter of that namespace
+func (ad *AdminRedis) Block(ctx context.Context, block *entity.Block) error {
+	_, redisErr := ad.redisCli.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		switch block.Kind {
+		case entity.BlockKindIP:
+			pipe.Set(ctx, createIPDurationPrefix(block.Namespace, block.Identity), entity.StatusIPBlocked, block.TTL)
+		case entity.BlockKindApiKey:
+			pipe.Set(ctx, createAPIKeyDurationPrefix(block.Identity), entity.StatusApiKeyBlock, block.TTL)
+		case entity.BlockKindCIDR:
+			expiresAt := time.Now().Add(block.TTL).UnixMilli()
+			pipe.ZAdd(ctx, entity.CIDRBlockKey, redis.Z{Score: float64(expiresAt), Member: block.Identity})
+			pipe.HSet(ctx, entity.CIDRBlockReasonKey, block.Identity, block.Reason)
+			return nil
+		default:
+			return entity.ErrInvalidBlockKind
+		}
+
+		pipe.Set(ctx, createBlockReasonPrefix(block.Kind, block.Namespace, block.Identity), block.Reason, block.TTL)
+		return nil
+	})
+	if redisErr != nil {
+		slog.ErrorContext(ctx, "error inserting manual block", "error", redisErr)
+		return redisErr
+	}
+
+	return nil
+}
+
+// Unblock lifts an active block, it returns false when there was none
+func (ad *AdminRedis) Unblock(ctx context.Context, kind, namespace, identity string) (bool, error) {
+	var removed *redis.IntCmd
+	_, redisErr := ad.redisCli.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		switch kind {
+		case entity.BlockKindIP:
+			removed = pipe.Del(ctx, createIPDurationPrefix(namespace, identity))
+		case entity.BlockKindApiKey:
+			removed = pipe.Del(ctx, createAPIKeyDurationPrefix(identity))
+		case entity.BlockKindCIDR:
+			removed = pipe.ZRem(ctx, entity.CIDRBlockKey, identity)
+			pipe.HDel(ctx, entity.CIDRBlockReasonKey, identity)
+			return nil
+		default:
+			return entity.ErrInvalidBlockKind
+		}
+
+		pipe.Del(ctx, createBlockReasonPrefix(kind, namespace, identity))
+		return nil
+	})
+	if redisErr != nil {
+		slog.ErrorContext(ctx, "error removing block", "error", redisErr)
+		return false, redisErr
+	}
+
+	return removed.Val() > 0, nil
+}
+
+// Reset drops the window counters and the offenses of an identity
+func (ad *AdminRedis) Reset(ctx context.Context, kind, namespace, identity string) error {
+	var keys []string
+	switch kind {
+	case entity.BlockKindIP:
+		keys = []string{createIPRatePrefix(namespace, identity), createIPOffensePrefix(namespace, identity)}
+	case entity.BlockKindApiKey:
+		keys = []string{createAPIKeyRatePrefix(identity), createAPIKeyOffensePrefix(identity)}
+	default:
+		return entity.ErrInvalidBlockKind
+	}
+
+	if redisErr := ad.redisCli.Del(ctx, keys...).Err(); redisErr != nil {
+		slog.ErrorContext(ctx, "error resetting identity counters", "error", redisErr)
+		return redisErr
+	}
+
+	return nil
+}
+
+// ListBlocked returns every identity currently blocked with its remaining TTL, the IP blocks of every namespace included
+func (ad *AdminRedis) ListBlocked(ctx context.Context) ([]entity.Block, error) {
+	blocks := make([]entity.Block, 0)
+
+	for kind, prefix := range map[string]string{
+		entity.BlockKindIP:     entity.IPPrefixBlockDurationKey,
+		entity.BlockKindApiKey: entity.ApiKeyBlockDuration,
+	} {
+		keyBlocks, listErr := ad.listKeyBlocks(ctx, kind, prefix)
+		if listErr != nil {
+			return nil, listErr
+		}
+		blocks = append(blocks, keyBlocks...)
+	}
+
+	cidrBlocks, listErr := ad.listCIDRBlocks(ctx)
+	if listErr != nil {
+		return nil, listErr
+	}
+
+	return append(blocks, cidrBlocks...), nil
+}
+
+func (ad *AdminRedis) listKeyBlocks(ctx context.Context, kind, prefix string) ([]entity.Block, error) {
+	// the IP limiters keep their blocks in namespaces of their own, prefix:namespace_{identity}
+	match := fmt.Sprintf("%s_*", prefix)
+	if kind == entity.BlockKindIP {
+		match = fmt.Sprintf("%s*", prefix)
+	}
+	keys, scanErr := scanKeys(ctx, ad.redisCli, match)
+	if scanErr != nil {
+		slog.ErrorContext(ctx, "error listing blocked keys", "error", scanErr)
+		return nil, scanErr
+	}
+
+	blocks := make([]entity.Block, 0, len(keys))
+	for _, key := range keys {
+		namespace, identity, ok := namespacedIdentityOf(key, prefix)
+		if !ok {
+			continue
+		}
+
+		ttl, ttlErr := ad.redisCli.PTTL(ctx, key).Result()
+		if ttlErr != nil {
+			return nil, ttlErr
+		}
+		if ttl < 0 {
+			// the key expired in between or has no TTL at all
+			continue
+		}
+
+		reason, reasonErr := ad.redisCli.Get(ctx, createBlockReasonPrefix(kind, namespace, identity)).Result()
+		if errors.Is(reasonErr, redis.Nil) {
+			reason = entity.DefaultBlockReason
+		} else if reasonErr != nil {
+			return nil, reasonErr
+		}
+
+		blocks = append(blocks, entity.Block{
+			Kind:      kind,
+			Namespace: namespace,
+			Identity:  identity,
+			Reason:    reason,
+			TTL:       ttl,
+		})
+	}
+
+	return blocks, nil
+}
+
+// scanKeys returns the keys matching the pattern, on redis cluster every master is scanned
+func scanKeys(ctx context.Context, redisCli redis.UniversalClient, match string) ([]string, error) {
+	cluster, ok := redisCli.(*redis.ClusterClient)
+	if !ok {
+		return scanNode(ctx, redisCli, match)
+	}
+
+	var lock sync.Mutex
+	keys := make([]string, 0)
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+		nodeKeys, err := scanNode(ctx, node, match)
+		if err != nil {
+			return err
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+		keys = append(keys, nodeKeys...)
+		return nil
+	})
+
+	return keys, err
+}
+
+func scanNode(ctx context.Context, redisCli redis.Cmdable, match string) ([]string, error) {
+	keys := make([]string, 0)
+	iter := redisCli.Scan(ctx, 0, match, 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+
+	return keys, iter.Err()
+}
+
+func (ad *AdminRedis) listCIDRBlocks(ctx context.Context) ([]entity.Block, error) {
+	now := time.Now()
+	if redisErr := ad.redisCli.ZRemRangeByScore(
+		ctx,
+		entity.CIDRBlockKey,
+		"-inf",
+		strconv.FormatInt(now.UnixMilli(), 10),
+	).Err(); redisErr != nil {
+		return nil, redisErr
+	}
+
+	ranges, rangeErr := ad.redisCli.ZRangeWithScores(ctx, entity.CIDRBlockKey, 0, -1).Result()
+	if rangeErr != nil {
+		slog.ErrorContext(ctx, "error listing blocked CIDR ranges", "error", rangeErr)
+		return nil, rangeErr
+	}
+
+	blocks := make([]entity.Block, 0, len(ranges))
+	for _, r := range ranges {
+		identity := fmt.Sprint(r.Member)
+		reason, reasonErr := ad.redisCli.HGet(ctx, entity.CIDRBlockReasonKey, identity).Result()
+		if reasonErr != nil && !errors.Is(reasonErr, redis.Nil) {
+			return nil, reasonErr
+		}
+
+		blocks = append(blocks, entity.Block{
+			Kind:     entity.BlockKindCIDR,
+			Identity: identity,
+			Reason:   reason,
+			TTL:      time.UnixMilli(int64(r.Score)).Sub(now),
+		})
+	}
+
+	return blocks, nil
+}
+
+func createBlockReasonPrefix(kind, namespace, identity string) string {
+	prefix := namespacedPrefix(fmt.Sprintf("%s:%s", entity.BlockReasonPrefix, kind), namespace)
+	return fmt.Sprintf("%s_%s", prefix, hashTag(identity))
+}
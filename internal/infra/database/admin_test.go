@@ -0,0 +1,75 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminManagesEveryNamespace(t *testing.T) {
+	ctx := context.Background()
+	redisServer := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: redisServer.Addr()})
+	admin := NewAdminRedis(client)
+
+	failures := NewIPRedisWithNamespace(client, "failures/login")
+	grpc := NewIPRedisWithNamespace(client, "grpc:/auth.v1.AuthService/Login")
+	require.NoError(t, failures.SaveBlockedDuration(ctx, "user:42", 60))
+	require.NoError(t, grpc.SaveBlockedDuration(ctx, "api-key_abc", 60))
+	require.NoError(t, admin.Block(ctx, &entity.Block{Kind: entity.BlockKindIP, Identity: "10.0.0.1", Reason: "abuse", TTL: time.Minute}))
+
+	blocks, err := admin.ListBlocked(ctx)
+	require.NoError(t, err)
+	listed := make(map[string]string)
+	for _, block := range blocks {
+		listed[block.Namespace+" "+block.Identity] = block.Reason
+	}
+	assert.Equal(t, map[string]string{
+		" 10.0.0.1":              "abuse",
+		"failures/login user:42": entity.DefaultBlockReason,
+		"grpc:/auth.v1.AuthService/Login api-key_abc": entity.DefaultBlockReason,
+	}, listed)
+
+	removed, err := admin.Unblock(ctx, entity.BlockKindIP, "failures/login", "user:42")
+	require.NoError(t, err)
+	assert.True(t, removed)
+	status, err := failures.GetBlockedDuration(ctx, "user:42")
+	require.NoError(t, err)
+	assert.Empty(t, status, "the block of the failures limiter is lifted")
+
+	require.NoError(t, grpc.UpsertRequest(ctx, "api-key_abc", &entity.RateLimiter{Req: []time.Time{time.Now()}}))
+	require.NoError(t, admin.Reset(ctx, entity.BlockKindIP, "grpc:/auth.v1.AuthService/Login", "api-key_abc"))
+	stored, err := grpc.GetRequest(ctx, "api-key_abc")
+	require.NoError(t, err)
+	assert.Empty(t, stored.Req, "the counters of the gRPC limiter are reset")
+}
+
+func TestNamespacedIdentityOf(t *testing.T) {
+	tests := []struct {
+		key               string
+		expectedNamespace string
+		expectedIdentity  string
+		expectedOk        bool
+	}{
+		{key: "block:ip_{10.0.0.1}", expectedIdentity: "10.0.0.1", expectedOk: true},
+		{key: "block:ip:failures/login_{user:42}", expectedNamespace: "failures/login", expectedIdentity: "user:42", expectedOk: true},
+		{key: "block:ip:grpc:/a.B/C_{api-key_x_{y}", expectedNamespace: "grpc:/a.B/C", expectedIdentity: "api-key_x_{y", expectedOk: true},
+		{key: "block:ip:broken", expectedOk: false},
+		{key: "block:api-key_{abc}", expectedOk: false},
+	}
+
+	for i := 0; i < len(tests); i++ {
+		t.Run(tests[i].key, func(t *testing.T) {
+			namespace, identity, ok := namespacedIdentityOf(tests[i].key, entity.IPPrefixBlockDurationKey)
+			assert.Equal(t, tests[i].expectedOk, ok)
+			assert.Equal(t, tests[i].expectedNamespace, namespace)
+			assert.Equal(t, tests[i].expectedIdentity, identity)
+		})
+	}
+}
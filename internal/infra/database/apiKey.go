@@ -5,7 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
 	"github.com/MatheusBenetti/rate-limiter/internal/dto"
@@ -14,10 +14,10 @@ import (
 )
 
 type APIKeyRedis struct {
-	redisCli *redis.Client
+	redisCli redis.UniversalClient
 }
 
-func NewAPIKeyRedis(redisCli *redis.Client) *APIKeyRedis {
+func NewAPIKeyRedis(redisCli redis.UniversalClient) *APIKeyRedis {
 	return &APIKeyRedis{redisCli: redisCli}
 }
 
@@ -26,21 +26,22 @@ func (at *APIKeyRedis) Save(ctx context.Context, key *entity.ApiKey) (string, er
 		MaxReq:        key.RateLimiter.MaxReq,
 		TimeWindow:    key.RateLimiter.TimeWindow,
 		BlockDuration: key.BlockDuration,
+		Plan:          key.Plan,
 	}
 
 	jsonReq, marErr := json.Marshal(req)
 	if marErr != nil {
-		log.Println("error marshaling API Key")
+		slog.ErrorContext(ctx, "error marshaling API Key", "error", marErr)
 		return "", marErr
 	}
 
 	if redisErr := at.redisCli.Set(
 		ctx,
-		key.Value(),
+		createAPIKeyConfigPrefix(key.Value()),
 		jsonReq,
 		0,
 	).Err(); redisErr != nil {
-		log.Println("error inserting API Key value")
+		slog.ErrorContext(ctx, "error inserting API Key value", "error", redisErr)
 		return "", redisErr
 	}
 
@@ -48,18 +49,22 @@ func (at *APIKeyRedis) Save(ctx context.Context, key *entity.ApiKey) (string, er
 }
 
 func (at *APIKeyRedis) Get(ctx context.Context, value string) (*entity.ApiKey, error) {
-	val, getErr := at.redisCli.Get(ctx, value).Result()
+	val, getErr := at.redisCli.Get(ctx, createAPIKeyConfigPrefix(value)).Result()
+	if errors.Is(getErr, redis.Nil) {
+		return &entity.ApiKey{}, entity.ErrUnknownApiKey
+	}
 	if getErr != nil {
 		return &entity.ApiKey{}, getErr
 	}
 
 	var apiKeyConfigDB dto.Input
 	if err := json.Unmarshal([]byte(val), &apiKeyConfigDB); err != nil {
-		log.Println("API key configuration marshall error")
+		slog.ErrorContext(ctx, "API key configuration marshall error", "error", err)
 		return &entity.ApiKey{}, err
 	}
 
 	return &entity.ApiKey{
+		Plan:          apiKeyConfigDB.Plan,
 		BlockDuration: apiKeyConfigDB.BlockDuration,
 		RateLimiter: entity.RateLimiter{
 			TimeWindow: apiKeyConfigDB.TimeWindow,
@@ -69,33 +74,26 @@ func (at *APIKeyRedis) Get(ctx context.Context, value string) (*entity.ApiKey, e
 }
 
 func (at *APIKeyRedis) UpsertRequest(ctx context.Context, key string, rl *entity.RateLimiter) error {
-	req := dto.ApiKeyReqDb{
-		MaxReq:     rl.MaxReq,
-		TimeWindow: rl.TimeWindow,
-		Req: func() []int64 {
-			reqInt := make([]int64, 0)
-			for _, r := range rl.Req {
-				reqInt = append(reqInt, r.Unix())
-			}
-			return reqInt
-		}(),
-	}
-
-	jsonReq, marErr := json.Marshal(req)
+	jsonReq, marErr := encodeAPIKeyRequests(rl)
 	if marErr != nil {
-		log.Println("error marshaling API Key")
+		slog.ErrorContext(ctx, "error marshaling API Key", "error", marErr)
 		return marErr
 	}
 
 	redisErr := at.redisCli.Set(ctx, createAPIKeyRatePrefix(key), jsonReq, 0).Err()
 	if redisErr != nil {
-		log.Println("error inserting API Key value")
+		slog.ErrorContext(ctx, "error inserting API Key value", "error", redisErr)
 		return redisErr
 	}
 
 	return nil
 }
 
+// UpdateRequest changes the stored array of request atomically, no other client writes it between the read and the write
+func (at *APIKeyRedis) UpdateRequest(ctx context.Context, key string, update func(rl *entity.RateLimiter) bool) error {
+	return updateRequests(ctx, at.redisCli, createAPIKeyRatePrefix(key), decodeAPIKeyRequests, encodeAPIKeyRequests, update)
+}
+
 func (at *APIKeyRedis) SaveBlockedDuration(ctx context.Context, key string, BlockedDuration int64) error {
 	if redisErr := at.redisCli.Set(
 		ctx,
@@ -103,7 +101,7 @@ func (at *APIKeyRedis) SaveBlockedDuration(ctx context.Context, key string, Bloc
 		entity.StatusApiKeyBlock,
 		time.Second*time.Duration(BlockedDuration),
 	).Err(); redisErr != nil {
-		log.Println("error inserting SaveBlockedDuration on API Key")
+		slog.ErrorContext(ctx, "error inserting SaveBlockedDuration on API Key", "error", redisErr)
 		return redisErr
 	}
 
@@ -113,7 +111,7 @@ func (at *APIKeyRedis) SaveBlockedDuration(ctx context.Context, key string, Bloc
 func (at *APIKeyRedis) GetBlockedDuration(ctx context.Context, key string) (string, error) {
 	val, getErr := at.redisCli.Get(ctx, createAPIKeyDurationPrefix(key)).Result()
 	if errors.Is(getErr, redis.Nil) {
-		log.Println("API key does not exist")
+		slog.DebugContext(ctx, "api key block does not exist")
 		return "", nil
 	}
 	if getErr != nil {
@@ -127,7 +125,7 @@ func (at *APIKeyRedis) GetBlockedDuration(ctx context.Context, key string) (stri
 func (at *APIKeyRedis) GetRequest(ctx context.Context, key string) (*entity.RateLimiter, error) {
 	val, getErr := at.redisCli.Get(ctx, createAPIKeyRatePrefix(key)).Result()
 	if errors.Is(getErr, redis.Nil) {
-		log.Println("INFO: GetRequest API key does not exist")
+		slog.DebugContext(ctx, "api key requests do not exist")
 		return &entity.RateLimiter{
 			Req:        make([]time.Time, 0),
 			TimeWindow: 0,
@@ -138,10 +136,61 @@ func (at *APIKeyRedis) GetRequest(ctx context.Context, key string) (*entity.Rate
 		return nil, getErr
 	}
 
+	rateLimiter, decodeErr := decodeAPIKeyRequests(val)
+	if decodeErr != nil {
+		slog.ErrorContext(ctx, "API key RateLimiter unmarshal error", "error", decodeErr)
+		return &entity.RateLimiter{}, decodeErr
+	}
+
+	return rateLimiter, nil
+}
+
+// IncrOffense counts one more offense of the key, the count is forgotten decay seconds after the last one
+func (at *APIKeyRedis) IncrOffense(ctx context.Context, key string, decay int64) (int64, error) {
+	var incr *redis.IntCmd
+	if _, redisErr := at.redisCli.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, createAPIKeyOffensePrefix(key))
+		pipe.Expire(ctx, createAPIKeyOffensePrefix(key), time.Second*time.Duration(decay))
+		return nil
+	}); redisErr != nil {
+		slog.ErrorContext(ctx, "error incrementing offenses for API Key", "error", redisErr)
+		return 0, redisErr
+	}
+
+	return incr.Val(), nil
+}
+
+// DeleteRequest drops the stored array of request
+func (at *APIKeyRedis) DeleteRequest(ctx context.Context, key string) error {
+	if redisErr := at.redisCli.Del(ctx, createAPIKeyRatePrefix(key)).Err(); redisErr != nil {
+		slog.ErrorContext(ctx, "error deleting API Key requests", "error", redisErr)
+		return redisErr
+	}
+
+	return nil
+}
+
+func encodeAPIKeyRequests(rl *entity.RateLimiter) ([]byte, error) {
+	req := dto.ApiKeyReqDb{
+		MaxReq:     rl.MaxReq,
+		TimeWindow: rl.TimeWindow,
+		Req: func() []int64 {
+			reqInt := make([]int64, 0)
+			for _, r := range rl.Req {
+				reqInt = append(reqInt, r.Unix())
+			}
+			return reqInt
+		}(),
+		Cost: rl.Cost,
+	}
+
+	return json.Marshal(req)
+}
+
+func decodeAPIKeyRequests(val string) (*entity.RateLimiter, error) {
 	var rateLimiter dto.ApiKeyReqDb
 	if err := json.Unmarshal([]byte(val), &rateLimiter); err != nil {
-		log.Println("API key RateLimiter unmarshal error")
-		return &entity.RateLimiter{}, err
+		return nil, err
 	}
 
 	return &entity.RateLimiter{
@@ -152,15 +201,26 @@ func (at *APIKeyRedis) GetRequest(ctx context.Context, key string) (*entity.Rate
 			}
 			return reqTimeStamp
 		}(),
+		Cost:       rateLimiter.Cost,
 		TimeWindow: rateLimiter.TimeWindow,
 		MaxReq:     rateLimiter.MaxReq,
 	}, nil
 }
 
+// createAPIKeyConfigPrefix keeps the records of the keys apart from the limiter keys, so a client sending
+// the name of another key as its API key never reads it as a key configuration
+func createAPIKeyConfigPrefix(key string) string {
+	return fmt.Sprintf("%s_%s", entity.ApiKeyConfigKey, hashTag(key))
+}
+
 func createAPIKeyDurationPrefix(key string) string {
-	return fmt.Sprintf("%s_%s", entity.ApiKeyBlockDuration, key)
+	return fmt.Sprintf("%s_%s", entity.ApiKeyBlockDuration, hashTag(key))
 }
 
 func createAPIKeyRatePrefix(key string) string {
-	return fmt.Sprintf("%s_%s", entity.ApiKeyRateKey, key)
+	return fmt.Sprintf("%s_%s", entity.ApiKeyRateKey, hashTag(key))
+}
+
+func createAPIKeyOffensePrefix(key string) string {
+	return fmt.Sprintf("%s_%s", entity.ApiKeyOffenseKey, hashTag(key))
 }
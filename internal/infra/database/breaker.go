@@ -0,0 +1,143 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultBreakerThreshold   = 5
+	defaultBreakerOpenTimeout = 10 * time.Second
+)
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// Breaker is a circuit breaker around every command sent to redis. After threshold consecutive
+// failures it opens and fails fast with ErrBackendUnavailable, once openTimeout passes a single
+// probe command is let through and its outcome closes or reopens the circuit
+type Breaker struct {
+	mu          sync.Mutex
+	state       breakerState
+	failures    int
+	openedAt    time.Time
+	threshold   int
+	openTimeout time.Duration
+}
+
+func NewBreaker(threshold int, openTimeout time.Duration) *Breaker {
+	if threshold <= 0 {
+		threshold = defaultBreakerThreshold
+	}
+	if openTimeout <= 0 {
+		openTimeout = defaultBreakerOpenTimeout
+	}
+
+	return &Breaker{
+		threshold:   threshold,
+		openTimeout: openTimeout,
+	}
+}
+
+// Open reports whether the breaker is currently failing fast
+func (b *Breaker) Open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state == breakerOpen && time.Since(b.openedAt) < b.openTimeout
+}
+
+func (b *Breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.openTimeout {
+			return false
+		}
+		b.state = breakerHalfOpen
+		return true
+	case breakerHalfOpen:
+		// only the probe command goes through until it reports back
+		return false
+	default:
+		return true
+	}
+}
+
+func (b *Breaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !isBackendFailure(err) {
+		if b.state != breakerClosed {
+			slog.Info("redis circuit breaker closed")
+		}
+		b.state = breakerClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		if b.state != breakerOpen {
+			slog.Warn("redis circuit breaker opened", "error", err)
+		}
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// isBackendFailure tells the errors of an unhealthy redis apart from the replies of a healthy one
+func isBackendFailure(err error) bool {
+	if err == nil || errors.Is(err, redis.Nil) {
+		return false
+	}
+
+	var replyErr redis.Error
+	return !errors.As(err, &replyErr)
+}
+
+func (b *Breaker) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (b *Breaker) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if !b.allow() {
+			cmd.SetErr(entity.ErrBackendUnavailable)
+			return entity.ErrBackendUnavailable
+		}
+
+		err := next(ctx, cmd)
+		b.record(err)
+		return err
+	}
+}
+
+func (b *Breaker) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if !b.allow() {
+			for _, cmd := range cmds {
+				cmd.SetErr(entity.ErrBackendUnavailable)
+			}
+			return entity.ErrBackendUnavailable
+		}
+
+		err := next(ctx, cmds)
+		b.record(err)
+		return err
+	}
+}
@@ -0,0 +1,37 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreaker(t *testing.T) {
+	breaker := NewBreaker(2, 20*time.Millisecond)
+	failure := errors.New("dial tcp: connection refused")
+
+	assert.True(t, breaker.allow())
+	breaker.record(redis.Nil)
+	breaker.record(failure)
+	assert.False(t, breaker.Open(), "a single failure keeps the circuit closed")
+
+	breaker.record(failure)
+	assert.True(t, breaker.Open())
+	assert.False(t, breaker.allow())
+
+	time.Sleep(25 * time.Millisecond)
+	assert.True(t, breaker.allow(), "the probe goes through once the timeout passes")
+	assert.False(t, breaker.allow(), "only one probe at a time")
+
+	breaker.record(failure)
+	assert.True(t, breaker.Open(), "a failed probe reopens the circuit")
+
+	time.Sleep(25 * time.Millisecond)
+	assert.True(t, breaker.allow())
+	breaker.record(nil)
+	assert.False(t, breaker.Open())
+	assert.True(t, breaker.allow())
+}
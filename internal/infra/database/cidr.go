@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultCIDRBlocksTTL is how long the blocked CIDR ranges are kept in memory before being read again
+const DefaultCIDRBlocksTTL = time.Second
+
+type cidrBlock struct {
+	identity  string
+	expiresAt time.Time
+}
+
+// CIDRBlocks keeps the blocked CIDR ranges in the memory of the replica for a short TTL, so checking an IP
+// does not read the whole list from redis on every request. A new block takes up to the TTL to apply
+type CIDRBlocks struct {
+	redisCli redis.UniversalClient
+	ttl      time.Duration
+
+	mu        sync.Mutex
+	blocks    []cidrBlock
+	fetchedAt time.Time
+}
+
+func NewCIDRBlocks(redisCli redis.UniversalClient, ttl time.Duration) *CIDRBlocks {
+	return &CIDRBlocks{redisCli: redisCli, ttl: ttl}
+}
+
+// Active returns the ranges that did not expire yet
+func (c *CIDRBlocks) Active(ctx context.Context) ([]string, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	fresh := !c.fetchedAt.IsZero() && now.Sub(c.fetchedAt) < c.ttl
+	blocks := c.blocks
+	c.mu.Unlock()
+
+	if !fresh {
+		fetched, fetchErr := fetchCIDRBlocks(ctx, c.redisCli, now)
+		if fetchErr != nil {
+			return nil, fetchErr
+		}
+
+		c.mu.Lock()
+		c.blocks, c.fetchedAt = fetched, now
+		c.mu.Unlock()
+		blocks = fetched
+	}
+
+	ranges := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		if block.expiresAt.After(now) {
+			ranges = append(ranges, block.identity)
+		}
+	}
+
+	return ranges, nil
+}
+
+func fetchCIDRBlocks(ctx context.Context, redisCli redis.UniversalClient, now time.Time) ([]cidrBlock, error) {
+	members, rangeErr := redisCli.ZRangeByScoreWithScores(ctx, entity.CIDRBlockKey, &redis.ZRangeBy{
+		Min: strconv.FormatInt(now.UnixMilli(), 10),
+		Max: "+inf",
+	}).Result()
+	if rangeErr != nil {
+		return nil, rangeErr
+	}
+
+	blocks := make([]cidrBlock, 0, len(members))
+	for _, member := range members {
+		identity, _ := member.Member.(string)
+		blocks = append(blocks, cidrBlock{identity: identity, expiresAt: time.UnixMilli(int64(member.Score))})
+	}
+
+	return blocks, nil
+}
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCIDRBlocksCachesTheRanges(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	admin := NewAdminRedis(client)
+	require.NoError(t, admin.Block(ctx, &entity.Block{Kind: entity.BlockKindCIDR, Identity: "10.0.0.0/16", TTL: time.Hour}))
+
+	ip := NewIPRedis(client).WithCIDRBlocks(NewCIDRBlocks(client, 50*time.Millisecond))
+	status, err := ip.GetBlockedDuration(ctx, "10.0.1.1")
+	require.NoError(t, err)
+	assert.Equal(t, entity.StatusIPBlocked, status)
+
+	require.NoError(t, admin.Block(ctx, &entity.Block{Kind: entity.BlockKindCIDR, Identity: "192.168.0.0/24", TTL: time.Hour}))
+	commands := server.CommandCount()
+	status, err = ip.GetBlockedDuration(ctx, "192.168.0.1")
+	require.NoError(t, err)
+	assert.Empty(t, status, "a block added inside the TTL is not seen yet")
+	assert.Equal(t, 1, server.CommandCount()-commands, "only the IP block is read while the ranges are cached")
+
+	time.Sleep(60 * time.Millisecond)
+	status, err = ip.GetBlockedDuration(ctx, "192.168.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, entity.StatusIPBlocked, status)
+}
+
+func TestCIDRBlocksDropsExpiredRanges(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	require.NoError(t, NewAdminRedis(client).Block(ctx, &entity.Block{
+		Kind:     entity.BlockKindCIDR,
+		Identity: "10.0.0.0/16",
+		TTL:      30 * time.Millisecond,
+	}))
+
+	cidrBlocks := NewCIDRBlocks(client, time.Hour)
+	ranges, err := cidrBlocks.Active(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.0/16"}, ranges)
+
+	time.Sleep(40 * time.Millisecond)
+	ranges, err = cidrBlocks.Active(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, ranges, "a cached range stops applying once it expires")
+}
@@ -0,0 +1,26 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type HealthRedis struct {
+	redisCli redis.UniversalClient
+}
+
+func NewHealthRedis(redisCli redis.UniversalClient) *HealthRedis {
+	return &HealthRedis{redisCli: redisCli}
+}
+
+// Ping checks that redis answers, while the circuit breaker is open it fails right away
+func (h *HealthRedis) Ping(ctx context.Context) error {
+	if err := h.redisCli.Ping(ctx).Err(); err != nil {
+		slog.WarnContext(ctx, "error pinging redis", "error", err)
+		return err
+	}
+
+	return nil
+}
@@ -5,7 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
 	"github.com/MatheusBenetti/rate-limiter/internal/dto"
@@ -14,61 +14,79 @@ import (
 )
 
 type IPRedis struct {
-	redisCli *redis.Client
+	redisCli   redis.UniversalClient
+	namespace  string
+	cidrBlocks *CIDRBlocks
 }
 
-func NewIPRedis(redisCli *redis.Client) *IPRedis {
+func NewIPRedis(redisCli redis.UniversalClient) *IPRedis {
 	return &IPRedis{redisCli: redisCli}
 }
 
-func (ip *IPRedis) UpsertRequest(ctx context.Context, key string, rl *entity.RateLimiter) error {
-	req := dto.IpReqDb{
-		MaxReq:     rl.MaxReq,
-		TimeWindow: rl.TimeWindow,
-		Req: func() []int64 {
-			reqInt := make([]int64, 0)
-			for _, r := range rl.Req {
-				reqInt = append(reqInt, r.Unix())
-			}
-			return reqInt
-		}(),
-	}
+// NewIPRedisWithNamespace stores the requests and blocks apart from the default IP limiter keys
+func NewIPRedisWithNamespace(redisCli redis.UniversalClient, namespace string) *IPRedis {
+	return &IPRedis{redisCli: redisCli, namespace: namespace}
+}
+
+// WithCIDRBlocks reads the blocked CIDR ranges through the shared in-memory copy instead of redis
+func (ip *IPRedis) WithCIDRBlocks(cidrBlocks *CIDRBlocks) *IPRedis {
+	ip.cidrBlocks = cidrBlocks
+	return ip
+}
 
-	jsonReq, marErr := json.Marshal(req)
+func (ip *IPRedis) UpsertRequest(ctx context.Context, key string, rl *entity.RateLimiter) error {
+	jsonReq, marErr := encodeIPRequests(rl)
 	if marErr != nil {
-		log.Println("error marshaling IP")
+		slog.ErrorContext(ctx, "error marshaling IP", "error", marErr)
 		return marErr
 	}
 
-	redisErr := ip.redisCli.Set(ctx, createIPRatePrefix(key), jsonReq, 0).Err()
+	redisErr := ip.redisCli.Set(ctx, createIPRatePrefix(ip.namespace, key), jsonReq, 0).Err()
 	if redisErr != nil {
-		log.Println("error inserting IP value")
+		slog.ErrorContext(ctx, "error inserting IP value", "error", redisErr)
 		return redisErr
 	}
 
 	return nil
 }
 
+// UpdateRequest changes the stored array of request atomically, no other client writes it between the read and the write
+func (ip *IPRedis) UpdateRequest(ctx context.Context, key string, update func(rl *entity.RateLimiter) bool) error {
+	return updateRequests(ctx, ip.redisCli, createIPRatePrefix(ip.namespace, key), decodeIPRequests, encodeIPRequests, update)
+}
+
 // SaveBlockedDuration Stores the blocked duration amount by key
 func (ip *IPRedis) SaveBlockedDuration(ctx context.Context, key string, BlockedDuration int64) error {
 	if redisErr := ip.redisCli.Set(
 		ctx,
-		createIPDurationPrefix(key),
+		createIPDurationPrefix(ip.namespace, key),
 		entity.StatusIPBlocked,
 		time.Second*time.Duration(BlockedDuration),
 	).Err(); redisErr != nil {
-		log.Println("error inserting SaveBlockedDuration for IP")
+		slog.ErrorContext(ctx, "error inserting SaveBlockedDuration for IP", "error", redisErr)
 		return redisErr
 	}
 
 	return nil
 }
 
-// GetBlockedDuration Obtain the blocked duration by key
+// GetBlockedDuration Obtain the blocked duration by key, an IP inside a blocked CIDR range is blocked as well
 func (ip *IPRedis) GetBlockedDuration(ctx context.Context, key string) (string, error) {
-	val, getErr := ip.redisCli.Get(ctx, createIPDurationPrefix(key)).Result()
+	val, getErr := ip.redisCli.Get(ctx, createIPDurationPrefix(ip.namespace, key)).Result()
 	if errors.Is(getErr, redis.Nil) {
-		log.Println("INFO: GetBlockedDuration IP key does not exist")
+		cidrBlocks := ip.cidrBlocks
+		if cidrBlocks == nil {
+			cidrBlocks = NewCIDRBlocks(ip.redisCli, 0)
+		}
+		ranges, cidrErr := cidrBlocks.Active(ctx)
+		if cidrErr != nil {
+			return "", cidrErr
+		}
+		if _, blocked := entity.CIDRContains(ranges, key); blocked {
+			return entity.StatusIPBlocked, nil
+		}
+
+		slog.DebugContext(ctx, "ip key does not exist")
 		return "", nil
 	}
 	if getErr != nil {
@@ -80,9 +98,9 @@ func (ip *IPRedis) GetBlockedDuration(ctx context.Context, key string) (string,
 
 // GetRequest reads the stored array of request
 func (ip *IPRedis) GetRequest(ctx context.Context, key string) (*entity.RateLimiter, error) {
-	val, getErr := ip.redisCli.Get(ctx, createIPRatePrefix(key)).Result()
+	val, getErr := ip.redisCli.Get(ctx, createIPRatePrefix(ip.namespace, key)).Result()
 	if errors.Is(getErr, redis.Nil) {
-		log.Println("INFO: GetRequest IP key does not exist")
+		slog.DebugContext(ctx, "ip requests do not exist")
 		return &entity.RateLimiter{
 			Req:        make([]time.Time, 0),
 			TimeWindow: 0,
@@ -93,10 +111,61 @@ func (ip *IPRedis) GetRequest(ctx context.Context, key string) (*entity.RateLimi
 		return nil, getErr
 	}
 
+	rateLimiter, decodeErr := decodeIPRequests(val)
+	if decodeErr != nil {
+		slog.ErrorContext(ctx, "IP RateLimiter unmarshal error", "error", decodeErr)
+		return &entity.RateLimiter{}, decodeErr
+	}
+
+	return rateLimiter, nil
+}
+
+// IncrOffense counts one more offense of the key, the count is forgotten decay seconds after the last one
+func (ip *IPRedis) IncrOffense(ctx context.Context, key string, decay int64) (int64, error) {
+	var incr *redis.IntCmd
+	if _, redisErr := ip.redisCli.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, createIPOffensePrefix(ip.namespace, key))
+		pipe.Expire(ctx, createIPOffensePrefix(ip.namespace, key), time.Second*time.Duration(decay))
+		return nil
+	}); redisErr != nil {
+		slog.ErrorContext(ctx, "error incrementing offenses for IP", "error", redisErr)
+		return 0, redisErr
+	}
+
+	return incr.Val(), nil
+}
+
+// DeleteRequest drops the stored array of request
+func (ip *IPRedis) DeleteRequest(ctx context.Context, key string) error {
+	if redisErr := ip.redisCli.Del(ctx, createIPRatePrefix(ip.namespace, key)).Err(); redisErr != nil {
+		slog.ErrorContext(ctx, "error deleting IP requests", "error", redisErr)
+		return redisErr
+	}
+
+	return nil
+}
+
+func encodeIPRequests(rl *entity.RateLimiter) ([]byte, error) {
+	req := dto.IpReqDb{
+		MaxReq:     rl.MaxReq,
+		TimeWindow: rl.TimeWindow,
+		Req: func() []int64 {
+			reqInt := make([]int64, 0)
+			for _, r := range rl.Req {
+				reqInt = append(reqInt, r.Unix())
+			}
+			return reqInt
+		}(),
+		Cost: rl.Cost,
+	}
+
+	return json.Marshal(req)
+}
+
+func decodeIPRequests(val string) (*entity.RateLimiter, error) {
 	var rateLimiter dto.IpReqDb
 	if err := json.Unmarshal([]byte(val), &rateLimiter); err != nil {
-		log.Println("IP RateLimiter unmarshal error")
-		return &entity.RateLimiter{}, err
+		return nil, err
 	}
 
 	return &entity.RateLimiter{
@@ -107,15 +176,20 @@ func (ip *IPRedis) GetRequest(ctx context.Context, key string) (*entity.RateLimi
 			}
 			return reqTimeStamp
 		}(),
+		Cost:       rateLimiter.Cost,
 		TimeWindow: rateLimiter.TimeWindow,
 		MaxReq:     rateLimiter.MaxReq,
 	}, nil
 }
 
-func createIPDurationPrefix(ip string) string {
-	return fmt.Sprintf("%s_%s", entity.IPPrefixBlockDurationKey, ip)
+func createIPDurationPrefix(namespace, ip string) string {
+	return fmt.Sprintf("%s_%s", namespacedPrefix(entity.IPPrefixBlockDurationKey, namespace), hashTag(ip))
+}
+
+func createIPRatePrefix(namespace, ip string) string {
+	return fmt.Sprintf("%s_%s", namespacedPrefix(entity.IPPrefixRateKey, namespace), hashTag(ip))
 }
 
-func createIPRatePrefix(ip string) string {
-	return fmt.Sprintf("%s_%s", entity.IPPrefixRateKey, ip)
+func createIPOffensePrefix(namespace, ip string) string {
+	return fmt.Sprintf("%s_%s", namespacedPrefix(entity.IPPrefixOffenseKey, namespace), hashTag(ip))
 }
@@ -0,0 +1,157 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+)
+
+const memorySweepInterval = time.Minute
+
+type memoryRequests struct {
+	req       []time.Time
+	cost      []int
+	expiresAt time.Time
+}
+
+type memoryCounter struct {
+	count     int64
+	expiresAt time.Time
+}
+
+// IPMemory keeps the requests and blocks in the memory of the replica, it is the fallback
+// limiter used while redis is unavailable so its state is neither shared nor persisted
+type IPMemory struct {
+	mu        sync.Mutex
+	requests  map[string]memoryRequests
+	blocks    map[string]time.Time
+	offenses  map[string]memoryCounter
+	lastSweep time.Time
+}
+
+func NewIPMemory() *IPMemory {
+	return &IPMemory{
+		requests:  make(map[string]memoryRequests),
+		blocks:    make(map[string]time.Time),
+		offenses:  make(map[string]memoryCounter),
+		lastSweep: time.Now(),
+	}
+}
+
+func (m *IPMemory) UpsertRequest(_ context.Context, key string, rl *entity.RateLimiter) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.store(key, rl)
+	return nil
+}
+
+// UpdateRequest changes the stored array of request while holding the lock of the memory
+func (m *IPMemory) UpdateRequest(_ context.Context, key string, update func(rl *entity.RateLimiter) bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rl := m.load(key)
+	if update(rl) {
+		m.store(key, rl)
+	}
+	return nil
+}
+
+func (m *IPMemory) SaveBlockedDuration(_ context.Context, key string, BlockedDuration int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.blocks[key] = time.Now().Add(time.Second * time.Duration(BlockedDuration))
+	return nil
+}
+
+func (m *IPMemory) GetBlockedDuration(_ context.Context, key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if expiresAt, ok := m.blocks[key]; ok && time.Now().Before(expiresAt) {
+		return entity.StatusIPBlocked, nil
+	}
+
+	return "", nil
+}
+
+func (m *IPMemory) GetRequest(_ context.Context, key string) (*entity.RateLimiter, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.load(key), nil
+}
+
+func (m *IPMemory) DeleteRequest(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.requests, key)
+	return nil
+}
+
+func (m *IPMemory) IncrOffense(_ context.Context, key string, decay int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	counter := m.offenses[key]
+	if now.After(counter.expiresAt) {
+		counter.count = 0
+	}
+	counter.count++
+	counter.expiresAt = now.Add(time.Second * time.Duration(decay))
+	m.offenses[key] = counter
+
+	return counter.count, nil
+}
+
+func (m *IPMemory) load(key string) *entity.RateLimiter {
+	stored, ok := m.requests[key]
+	if !ok || time.Now().After(stored.expiresAt) {
+		return &entity.RateLimiter{Req: make([]time.Time, 0)}
+	}
+
+	return &entity.RateLimiter{
+		Req:  append([]time.Time(nil), stored.req...),
+		Cost: append([]int(nil), stored.cost...),
+	}
+}
+
+func (m *IPMemory) store(key string, rl *entity.RateLimiter) {
+	now := time.Now()
+	m.sweep(now)
+	m.requests[key] = memoryRequests{
+		req:       append([]time.Time(nil), rl.Req...),
+		cost:      append([]int(nil), rl.Cost...),
+		expiresAt: now.Add(rl.GetDurationTimeWindow()),
+	}
+}
+
+// sweep drops the expired entries so unique identities can't grow the maps forever
+func (m *IPMemory) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < memorySweepInterval {
+		return
+	}
+	m.lastSweep = now
+
+	for key, stored := range m.requests {
+		if now.After(stored.expiresAt) {
+			delete(m.requests, key)
+		}
+	}
+	for key, expiresAt := range m.blocks {
+		if now.After(expiresAt) {
+			delete(m.blocks, key)
+		}
+	}
+	for key, counter := range m.offenses {
+		if now.After(counter.expiresAt) {
+			delete(m.offenses, key)
+		}
+	}
+}
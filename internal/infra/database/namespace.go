@@ -0,0 +1,46 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// namespacedPrefix appends the namespace to a key prefix so different limiters never share keys
+func namespacedPrefix(prefix, namespace string) string {
+	if namespace == "" {
+		return prefix
+	}
+
+	return fmt.Sprintf("%s:%s", prefix, namespace)
+}
+
+// hashTag wraps the identity of a key in a redis cluster hash tag, so every key of an identity
+// lands on the same slot and can be used together in a transaction
+func hashTag(identity string) string {
+	return fmt.Sprintf("{%s}", identity)
+}
+
+// namespacedIdentityOf splits a key built as prefix_{identity} or prefix:namespace_{identity}, the namespace
+// comes from the configuration so the identity starts at its first tag. It returns false for any other key
+func namespacedIdentityOf(key, prefix string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(key, prefix)
+	if !ok || !strings.HasSuffix(rest, "}") {
+		return "", "", false
+	}
+
+	namespace := ""
+	if strings.HasPrefix(rest, ":") {
+		tag := strings.Index(rest, "_{")
+		if tag < 0 {
+			return "", "", false
+		}
+		namespace, rest = rest[1:tag], rest[tag:]
+	}
+
+	identity, ok := strings.CutPrefix(rest, "_{")
+	if !ok {
+		return "", "", false
+	}
+
+	return namespace, strings.TrimSuffix(identity, "}"), true
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/redis/go-redis/v9"
+)
+
+// acquireScript drops the expired leases and adds a new one when the semaphore has a free slot.
+// Leases are scored by their expiration in milliseconds, using the redis clock so replicas agree on it
+var acquireScript = redis.NewScript(`
+local now = redis.call('TIME')
+local nowMs = tonumber(now[1]) * 1000 + math.floor(tonumber(now[2]) / 1000)
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', nowMs)
+if redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[2]) then
+	return 0
+end
+redis.call('ZADD', KEYS[1], nowMs + tonumber(ARGV[3]), ARGV[1])
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return 1
+`)
+
+// refreshScript extends a lease that is still held, it returns 0 when the lease already expired
+var refreshScript = redis.NewScript(`
+local now = redis.call('TIME')
+local nowMs = tonumber(now[1]) * 1000 + math.floor(tonumber(now[2]) / 1000)
+local expiresAt = redis.call('ZSCORE', KEYS[1], ARGV[1])
+if not expiresAt or tonumber(expiresAt) <= nowMs then
+	return 0
+end
+redis.call('ZADD', KEYS[1], nowMs + tonumber(ARGV[2]), ARGV[1])
+if redis.call('PTTL', KEYS[1]) < tonumber(ARGV[2]) then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 1
+`)
+
+type SemaphoreRedis struct {
+	redisCli redis.UniversalClient
+}
+
+func NewSemaphoreRedis(redisCli redis.UniversalClient) *SemaphoreRedis {
+	return &SemaphoreRedis{redisCli: redisCli}
+}
+
+// Acquire takes a slot of the semaphore for the lease when less than limit leases are held
+func (s *SemaphoreRedis) Acquire(ctx context.Context, lease *entity.Lease, limit int) (bool, error) {
+	acquired, err := acquireScript.Run(
+		ctx,
+		s.redisCli,
+		[]string{lease.Key},
+		lease.ID(),
+		limit,
+		lease.TTL.Milliseconds(),
+	).Int()
+	if err != nil {
+		slog.ErrorContext(ctx, "error acquiring semaphore lease", "error", err)
+		return false, err
+	}
+
+	return acquired == 1, nil
+}
+
+// Refresh extends the lease expiration by its TTL
+func (s *SemaphoreRedis) Refresh(ctx context.Context, lease *entity.Lease) (bool, error) {
+	refreshed, err := refreshScript.Run(
+		ctx,
+		s.redisCli,
+		[]string{lease.Key},
+		lease.ID(),
+		lease.TTL.Milliseconds(),
+	).Int()
+	if err != nil {
+		slog.ErrorContext(ctx, "error refreshing semaphore lease", "error", err)
+		return false, err
+	}
+
+	return refreshed == 1, nil
+}
+
+// Release gives the slot back to the semaphore
+func (s *SemaphoreRedis) Release(ctx context.Context, lease *entity.Lease) error {
+	if redisErr := s.redisCli.ZRem(ctx, lease.Key, lease.ID()).Err(); redisErr != nil {
+		slog.ErrorContext(ctx, "error releasing semaphore lease", "error", redisErr)
+		return redisErr
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/redis/go-redis/v9"
+)
+
+// errPoolTimeout is the message of the unexported error redis returns when no connection of the pool frees up in time
+const errPoolTimeout = "redis: connection pool timeout"
+
+// Unavailable tells the errors of a storage that could not be reached, or did not answer in time, apart from
+// the errors of a request it did answer, such as a malformed record. Only the first ones leave the decision to
+// the fail mode, the others have to reject the request
+func Unavailable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, entity.ErrBackendUnavailable) ||
+		errors.Is(err, errUpdateContended) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, redis.ErrClosed) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		err.Error() == errPoolTimeout {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
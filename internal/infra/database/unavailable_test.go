@@ -0,0 +1,45 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnavailable(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: redisServer.Addr()})
+	redisServer.Set("rate:ip_{10.0.0.1}", "[]")
+	wrongType := client.LPush(context.Background(), "rate:ip_{10.0.0.1}", "1").Err()
+	malformed := json.Unmarshal([]byte("{"), &struct{}{})
+
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "no error", err: nil, expected: false},
+		{name: "open circuit", err: entity.ErrBackendUnavailable, expected: true},
+		{name: "connection refused", err: &net.OpError{Op: "dial", Net: "tcp", Err: fmt.Errorf("connection refused")}, expected: true},
+		{name: "wrapped timeout", err: fmt.Errorf("get: %w", context.DeadlineExceeded), expected: true},
+		{name: "contended update", err: errUpdateContended, expected: true},
+		{name: "closed client", err: redis.ErrClosed, expected: true},
+		{name: "redis reply", err: wrongType, expected: false},
+		{name: "malformed record", err: malformed, expected: false},
+		{name: "invalid limits", err: entity.ErrTimeWindow, expected: false},
+		{name: "canceled request", err: context.Canceled, expected: false},
+	}
+
+	for i := 0; i < len(tests); i++ {
+		t.Run(tests[i].name, func(t *testing.T) {
+			assert.Equal(t, tests[i].expected, Unavailable(tests[i].err))
+		})
+	}
+}
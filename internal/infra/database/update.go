@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand"
+	"time"
+
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/redis/go-redis/v9"
+)
+
+// maxUpdateAttempts bounds the retries of an update that keeps losing the race for its key, the attempts
+// are spread by a random wait growing by updateBackoff each time so the contenders stop colliding
+const (
+	maxUpdateAttempts = 64
+	updateBackoff     = time.Millisecond
+)
+
+// errUpdateContended is returned when every attempt of an update lost the race for its key
+var errUpdateContended = errors.New("stored requests changed by another client on every attempt")
+
+// updateRequests runs update on the requests stored at key inside an optimistic transaction, the update
+// is run again when another client changed the key meanwhile, and nothing is written when it returns false
+func updateRequests(
+	ctx context.Context,
+	redisCli redis.UniversalClient,
+	key string,
+	decode func(val string) (*entity.RateLimiter, error),
+	encode func(rl *entity.RateLimiter) ([]byte, error),
+	update func(rl *entity.RateLimiter) bool,
+) error {
+	transaction := func(tx *redis.Tx) error {
+		rl := &entity.RateLimiter{Req: make([]time.Time, 0)}
+		val, getErr := tx.Get(ctx, key).Result()
+		if getErr != nil && !errors.Is(getErr, redis.Nil) {
+			return getErr
+		}
+		if getErr == nil {
+			decoded, decodeErr := decode(val)
+			if decodeErr != nil {
+				return decodeErr
+			}
+			rl = decoded
+		}
+
+		if !update(rl) {
+			return nil
+		}
+
+		jsonReq, marErr := encode(rl)
+		if marErr != nil {
+			return marErr
+		}
+		_, txErr := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, jsonReq, 0)
+			return nil
+		})
+		return txErr
+	}
+
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		err := redisCli.Watch(ctx, transaction, key)
+		if errors.Is(err, redis.TxFailedErr) {
+			timer := time.NewTimer(time.Duration(rand.Int63n(int64(attempt+1) * int64(updateBackoff))))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+			continue
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "error updating the stored requests", "error", err)
+		}
+		return err
+	}
+
+	slog.ErrorContext(ctx, "error updating the stored requests", "error", errUpdateContended)
+	return errUpdateContended
+}
@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/MatheusBenetti/rate-limiter/config"
+	"github.com/MatheusBenetti/rate-limiter/internal/dto"
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/MatheusBenetti/rate-limiter/internal/usecase"
+)
+
+// AdaptiveState reports the state of the adaptive limits
+type AdaptiveState interface {
+	State() dto.AdaptiveOutput
+}
+
+type AdminHandler struct {
+	repository entity.AdminRepository
+	config     *config.Store
+	adaptive   AdaptiveState
+}
+
+// NewAdminHandler builds the admin handlers, adaptive is nil while the adaptive limits are disabled
+func NewAdminHandler(repository entity.AdminRepository, config *config.Store, adaptive AdaptiveState) *AdminHandler {
+	return &AdminHandler{repository: repository, config: config, adaptive: adaptive}
+}
+
+// authorized checks the admin token, the admin endpoints answer 404 while no token is configured
+func (ah *AdminHandler) authorized(w http.ResponseWriter, r *http.Request) bool {
+	token := ah.config.Current().Admin.Token
+	if token == "" {
+		http.NotFound(w, r)
+		return false
+	}
+
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get(entity.AdminTokenHeader)), []byte(token)) != 1 {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return false
+	}
+
+	return true
+}
+
+func (ah *AdminHandler) Block(w http.ResponseWriter, r *http.Request) {
+	if !ah.authorized(w, r) {
+		return
+	}
+
+	input := dto.BlockInput{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		slog.WarnContext(r.Context(), "error decoding input data", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, execErr := usecase.NewManageBlocksUseCase(ah.repository).Block(r.Context(), input)
+	if execErr != nil {
+		http.Error(w, execErr.Error(), adminErrorStatus(execErr))
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, result)
+}
+
+func (ah *AdminHandler) Unblock(w http.ResponseWriter, r *http.Request) {
+	if !ah.authorized(w, r) {
+		return
+	}
+
+	input := dto.IdentityInput{
+		Kind:      r.URL.Query().Get("kind"),
+		Namespace: r.URL.Query().Get("namespace"),
+		Identity:  r.URL.Query().Get("identity"),
+	}
+	if execErr := usecase.NewManageBlocksUseCase(ah.repository).Unblock(r.Context(), input); execErr != nil {
+		http.Error(w, execErr.Error(), adminErrorStatus(execErr))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (ah *AdminHandler) Reset(w http.ResponseWriter, r *http.Request) {
+	if !ah.authorized(w, r) {
+		return
+	}
+
+	input := dto.IdentityInput{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		slog.WarnContext(r.Context(), "error decoding input data", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if execErr := usecase.NewManageBlocksUseCase(ah.repository).Reset(r.Context(), input); execErr != nil {
+		http.Error(w, execErr.Error(), adminErrorStatus(execErr))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (ah *AdminHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !ah.authorized(w, r) {
+		return
+	}
+
+	result, execErr := usecase.NewManageBlocksUseCase(ah.repository).List(r.Context())
+	if execErr != nil {
+		http.Error(w, execErr.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// Adaptive reports the factor and the effective by_ip limit of this replica
+func (ah *AdminHandler) Adaptive(w http.ResponseWriter, r *http.Request) {
+	if !ah.authorized(w, r) {
+		return
+	}
+
+	if ah.adaptive == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ah.adaptive.State())
+}
+
+func adminErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, entity.ErrBlockNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, entity.ErrInvalidBlockKind),
+		errors.Is(err, entity.ErrInvalidIdentity),
+		errors.Is(err, entity.ErrInvalidNamespace),
+		errors.Is(err, entity.ErrBlockTimeDuration):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, output any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(output); err != nil {
+		slog.Error("error encoding output data", "error", err)
+	}
+}
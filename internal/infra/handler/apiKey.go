@@ -2,9 +2,11 @@ package handler
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
 
+	"github.com/MatheusBenetti/rate-limiter/config"
 	"github.com/MatheusBenetti/rate-limiter/internal/dto"
 	"github.com/MatheusBenetti/rate-limiter/internal/entity"
 	"github.com/MatheusBenetti/rate-limiter/internal/usecase"
@@ -12,24 +14,29 @@ import (
 
 type APIKeyHandler struct {
 	repository entity.ApiKeyRepository
+	config     *config.Store
 }
 
-func NewAPIKeyHandler(repository entity.ApiKeyRepository) *APIKeyHandler {
-	return &APIKeyHandler{repository: repository}
+func NewAPIKeyHandler(repository entity.ApiKeyRepository, config *config.Store) *APIKeyHandler {
+	return &APIKeyHandler{repository: repository, config: config}
 }
 
 func (at *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
 	input := dto.Input{}
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		log.Println("error decoding input data:", err.Error())
+		slog.WarnContext(r.Context(), "error decoding input data", "error", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	apiKeyUseCase := usecase.NewCreateAPIKeyUseCase(at.repository)
+	apiKeyUseCase := usecase.NewCreateAPIKeyUseCase(at.repository, at.config.Current())
 	result, execErr := apiKeyUseCase.Execute(r.Context(), input)
+	if errors.Is(execErr, entity.ErrUnknownPlan) {
+		http.Error(w, execErr.Error(), http.StatusBadRequest)
+		return
+	}
 	if execErr != nil {
-		log.Println("error decoding input data:", execErr.Error())
+		slog.ErrorContext(r.Context(), "error creating API key", "error", execErr)
 		http.Error(w, execErr.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/MatheusBenetti/rate-limiter/config"
+	"github.com/MatheusBenetti/rate-limiter/internal/dto"
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/metrics"
+	"github.com/MatheusBenetti/rate-limiter/internal/usecase"
+)
+
+// decisionStrategy labels the decisions of the decision API in the metrics
+const decisionStrategy = "decisions"
+
+// DecisionHandler serves the JSON decision API, a denied descriptor is still answered with 200
+// and only the failures of the request or of the storage change the status
+type DecisionHandler struct {
+	repository func(namespace string) entity.IPRepository
+	config     *config.Store
+	metrics    *metrics.Metrics
+}
+
+func NewDecisionHandler(
+	repository func(namespace string) entity.IPRepository,
+	config *config.Store,
+	metrics *metrics.Metrics,
+) *DecisionHandler {
+	return &DecisionHandler{repository: repository, config: config, metrics: metrics}
+}
+
+// authorized checks the token of the caller, the decision API answers 404 while no token is configured
+func (dh *DecisionHandler) authorized(w http.ResponseWriter, r *http.Request) bool {
+	token := dh.config.Current().Decisions.Token
+	if token == "" {
+		http.NotFound(w, r)
+		return false
+	}
+
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get(entity.ApiTokenHeader)), []byte(token)) != 1 {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return false
+	}
+
+	return true
+}
+
+func (dh *DecisionHandler) Check(w http.ResponseWriter, r *http.Request) {
+	if !dh.authorized(w, r) {
+		return
+	}
+
+	input := dto.CheckInput{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		slog.WarnContext(r.Context(), "error decoding input data", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, execErr := usecase.NewDecideUseCase(dh.repository, dh.config.Current().Decisions).Check(r.Context(), input)
+	if execErr != nil {
+		dh.failed(w, r, execErr)
+		return
+	}
+
+	if !input.Peek {
+		dh.record(result)
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (dh *DecisionHandler) Batch(w http.ResponseWriter, r *http.Request) {
+	if !dh.authorized(w, r) {
+		return
+	}
+
+	input := dto.BatchCheckInput{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		slog.WarnContext(r.Context(), "error decoding input data", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, execErr := usecase.NewDecideUseCase(dh.repository, dh.config.Current().Decisions).Batch(r.Context(), input)
+	if execErr != nil {
+		dh.failed(w, r, execErr)
+		return
+	}
+
+	if !input.Peek {
+		for _, decision := range result.Descriptors {
+			dh.record(decision)
+		}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (dh *DecisionHandler) record(decision dto.DecisionOutput) {
+	outcome := metrics.DecisionAllowed
+	if !decision.Allowed {
+		outcome = metrics.DecisionLimited
+	}
+	dh.metrics.Decision(decisionStrategy, decision.Policy, "", outcome)
+}
+
+func (dh *DecisionHandler) failed(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, entity.ErrInvalidDescriptor),
+		errors.Is(err, entity.ErrUnknownPolicy),
+		errors.Is(err, entity.ErrDuplicateDescriptor),
+		errors.Is(err, entity.ErrEmptyBatch),
+		errors.Is(err, entity.ErrRequestCost):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		dh.metrics.Decision(decisionStrategy, "", "", metrics.DecisionUnavailable)
+		slog.ErrorContext(r.Context(), "error deciding on the descriptors", "error", err)
+		http.Error(w, entity.ErrBackendUnavailable.Error(), http.StatusServiceUnavailable)
+	}
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/MatheusBenetti/rate-limiter/config"
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/MatheusBenetti/rate-limiter/internal/usecase"
+)
+
+const readinessTimeout = 2 * time.Second
+
+type HealthHandler struct {
+	repository entity.HealthRepository
+	config     *config.Store
+}
+
+func NewHealthHandler(repository entity.HealthRepository, config *config.Store) *HealthHandler {
+	return &HealthHandler{repository: repository, config: config}
+}
+
+// Liveness answers as long as the process is able to serve requests
+func (hh *HealthHandler) Liveness(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readiness answers 503 while the limiter is not able to decide on requests
+func (hh *HealthHandler) Readiness(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	checkHealth := usecase.NewCheckHealthUseCase(hh.repository, hh.config.Current())
+	output, ready := checkHealth.Execute(ctx)
+	if !ready {
+		writeJSON(w, http.StatusServiceUnavailable, output)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, output)
+}
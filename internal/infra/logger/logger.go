@@ -0,0 +1,112 @@
+package logger
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/MatheusBenetti/rate-limiter/config"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Fields shared by the records of the limiter
+const (
+	RequestIDKey    = "request_id"
+	StrategyKey     = "strategy"
+	IdentityHashKey = "identity_hash"
+	PolicyKey       = "policy"
+	DecisionKey     = "decision"
+)
+
+// New builds the logger described by the configuration
+func New(w io.Writer, cfg config.Log) (*slog.Logger, error) {
+	level, err := parseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	options := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case config.LogFormatJSON:
+		handler = slog.NewJSONHandler(w, options)
+	case config.LogFormatText, "":
+		handler = slog.NewTextHandler(w, options)
+	default:
+		return nil, fmt.Errorf("unknown log format %q", cfg.Format)
+	}
+
+	initial, thereafter := cfg.Sampling.Initial, cfg.Sampling.Thereafter
+	if initial == 0 {
+		initial = defaultSamplingInitial
+	}
+	if thereafter == 0 {
+		thereafter = defaultSamplingThereafter
+	}
+	if initial > 0 {
+		handler = newSampler(handler, initial, thereafter)
+	}
+
+	return slog.New(&contextHandler{Handler: handler}), nil
+}
+
+func parseLevel(value string) (slog.Level, error) {
+	if value == "" {
+		return slog.LevelInfo, nil
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		return level, fmt.Errorf("unknown log level %q", value)
+	}
+
+	return level, nil
+}
+
+// HashIdentity hides the IP or API key of a request in the logs while keeping its records correlated
+func HashIdentity(identity string) string {
+	sum := sha256.Sum256([]byte(identity))
+	return hex.EncodeToString(sum[:8])
+}
+
+type attrsKey struct{}
+
+// WithAttrs returns a copy of ctx whose records carry attrs besides the ones ctx already had
+func WithAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	current, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	merged := make([]slog.Attr, 0, len(current)+len(attrs))
+	merged = append(merged, current...)
+	merged = append(merged, attrs...)
+
+	return context.WithValue(ctx, attrsKey{}, merged)
+}
+
+// contextHandler adds the request id and the attrs stored by WithAttrs to the records logged with a context
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		if requestID := middleware.GetReqID(ctx); requestID != "" {
+			r.AddAttrs(slog.String(RequestIDKey, requestID))
+		}
+		if attrs, ok := ctx.Value(attrsKey{}).([]slog.Attr); ok {
+			r.AddAttrs(attrs...)
+		}
+	}
+
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
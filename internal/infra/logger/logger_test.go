@@ -0,0 +1,54 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/MatheusBenetti/rate-limiter/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSampling(t *testing.T) {
+	var out bytes.Buffer
+	log, err := New(&out, config.Log{Sampling: config.LogSampling{Initial: 2, Thereafter: 3}})
+	assert.NoError(t, err)
+
+	for i := 0; i < 7; i++ {
+		log.Info("too many requests")
+	}
+	log.Info("request served")
+	log.Error("redis is down")
+	log.Error("redis is down")
+	log.Error("redis is down")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, 3, strings.Count(out.String(), "too many requests"), "the first 2 and then 1 every 3")
+	assert.Equal(t, 1, strings.Count(out.String(), "request served"), "messages are sampled apart")
+	assert.Equal(t, 3, strings.Count(out.String(), "redis is down"), "errors are never dropped")
+	assert.Len(t, lines, 7)
+}
+
+func TestContextAttrs(t *testing.T) {
+	var out bytes.Buffer
+	log, err := New(&out, config.Log{Format: config.LogFormatJSON, Level: "debug"})
+	assert.NoError(t, err)
+
+	ctx := WithAttrs(context.Background(), slog.String(StrategyKey, "ip"))
+	ctx = WithAttrs(ctx, slog.String(IdentityHashKey, HashIdentity("ip_10.0.0.1")))
+	log.DebugContext(ctx, "request allowed")
+
+	assert.Contains(t, out.String(), `"strategy":"ip"`)
+	assert.Contains(t, out.String(), `"identity_hash":"`+HashIdentity("ip_10.0.0.1")+`"`)
+	assert.NotContains(t, out.String(), "10.0.0.1")
+}
+
+func TestNewInvalidConfig(t *testing.T) {
+	_, err := New(&bytes.Buffer{}, config.Log{Level: "verbose"})
+	assert.Error(t, err)
+
+	_, err = New(&bytes.Buffer{}, config.Log{Format: "xml"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,72 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	samplingWindow            = time.Second
+	defaultSamplingInitial    = 10
+	defaultSamplingThereafter = 100
+)
+
+// sampler lets through the first initial records with the same message every second and then one
+// every thereafter records, so a burst of rejected requests does not flood the logs. Errors are never dropped
+type sampler struct {
+	slog.Handler
+	initial    int
+	thereafter int
+	state      *samplerState
+}
+
+type samplerState struct {
+	lock   sync.Mutex
+	window time.Time
+	counts map[string]int
+}
+
+func newSampler(next slog.Handler, initial, thereafter int) *sampler {
+	return &sampler{
+		Handler:    next,
+		initial:    initial,
+		thereafter: thereafter,
+		state:      &samplerState{counts: make(map[string]int)},
+	}
+}
+
+func (s *sampler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < slog.LevelError && !s.allow(r.Message, r.Time) {
+		return nil
+	}
+
+	return s.Handler.Handle(ctx, r)
+}
+
+func (s *sampler) allow(message string, now time.Time) bool {
+	s.state.lock.Lock()
+	defer s.state.lock.Unlock()
+
+	if now.Sub(s.state.window) >= samplingWindow {
+		s.state.window = now
+		clear(s.state.counts)
+	}
+
+	s.state.counts[message]++
+	seen := s.state.counts[message]
+	if seen <= s.initial {
+		return true
+	}
+
+	return s.thereafter > 0 && (seen-s.initial)%s.thereafter == 0
+}
+
+func (s *sampler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &sampler{Handler: s.Handler.WithAttrs(attrs), initial: s.initial, thereafter: s.thereafter, state: s.state}
+}
+
+func (s *sampler) WithGroup(name string) slog.Handler {
+	return &sampler{Handler: s.Handler.WithGroup(name), initial: s.initial, thereafter: s.thereafter, state: s.state}
+}
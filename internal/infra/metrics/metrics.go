@@ -0,0 +1,207 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "rate_limiter"
+
+// Decisions reported by the limiter
+const (
+	DecisionAllowed     = "allowed"
+	DecisionLimited     = "limited"
+	DecisionBlocked     = "blocked"
+	DecisionUnavailable = "unavailable"
+	DecisionShed        = "shed"
+	DecisionCanceled    = "canceled"
+)
+
+// OtherRoute labels the requests to paths without a route policy, so raw paths never become labels
+const OtherRoute = "other"
+
+// Metrics holds the prometheus collectors of the limiter. Labels only take values from the
+// configuration or from fixed sets, IPs and API keys are never used as labels
+type Metrics struct {
+	registry          *prometheus.Registry
+	decisions         *prometheus.CounterVec
+	repositoryLatency *prometheus.HistogramVec
+	configReloads     *prometheus.CounterVec
+	adaptiveFactor    prometheus.Gauge
+	effectiveLimit    *prometheus.GaugeVec
+	observedLatency   prometheus.Gauge
+	observedErrors    prometheus.Gauge
+}
+
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		decisions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "decisions_total",
+			Help:      "Requests evaluated by the limiter, by strategy, policy, route and decision.",
+		}, []string{"strategy", "policy", "route", "decision"}),
+		repositoryLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "repository_duration_seconds",
+			Help:      "Latency of the repository operations.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"repository", "operation"}),
+		configReloads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "config_reloads_total",
+			Help:      "Reloads of the configuration file, by result.",
+		}, []string{"result"}),
+		adaptiveFactor: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "adaptive_factor",
+			Help:      "Factor the limits are scaled by, one while the limited handlers are healthy.",
+		}),
+		effectiveLimit: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "effective_limit",
+			Help:      "Maximum requests of the policy after the adaptive factor.",
+		}, []string{"policy"}),
+		observedLatency: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "adaptive_latency_p99_seconds",
+			Help:      "p99 latency of the limited handlers in the last adaptive interval.",
+		}),
+		observedErrors: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "adaptive_error_ratio",
+			Help:      "Ratio of 5xx responses of the limited handlers in the last adaptive interval.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		m.decisions,
+		m.repositoryLatency,
+		m.configReloads,
+		m.adaptiveFactor,
+		m.effectiveLimit,
+		m.observedLatency,
+		m.observedErrors,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return m
+}
+
+// Handler serves the metrics in the prometheus exposition format, a failing collector does not hide the others
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError})
+}
+
+// Decision counts a request evaluated by the limiter, a nil Metrics records nothing
+func (m *Metrics) Decision(strategy, policy, route, decision string) {
+	if m == nil {
+		return
+	}
+
+	m.decisions.WithLabelValues(strategy, policy, route, decision).Inc()
+}
+
+// ConfigReloaded counts a reload of the configuration file, err is the reason it was rejected
+func (m *Metrics) ConfigReloaded(err error) {
+	result := "applied"
+	if err != nil {
+		result = "rejected"
+	}
+
+	m.configReloads.WithLabelValues(result).Inc()
+}
+
+// Adaptive reports the state of the adaptive limits after an interval, a nil Metrics records nothing
+func (m *Metrics) Adaptive(factor float64, byIpLimit int, p99 time.Duration, errorRatio float64) {
+	if m == nil {
+		return
+	}
+
+	m.adaptiveFactor.Set(factor)
+	m.effectiveLimit.WithLabelValues("by_ip").Set(float64(byIpLimit))
+	m.observedLatency.Set(p99.Seconds())
+	m.observedErrors.Set(errorRatio)
+}
+
+// WatchBlocked exposes the identities currently blocked by the admin endpoints. Listing them scans redis,
+// so the listing is reused by the scrapes of the next blockedListingTTL
+func (m *Metrics) WatchBlocked(repository entity.AdminRepository) {
+	m.registry.MustRegister(&blockedCollector{repository: repository, ttl: blockedListingTTL})
+}
+
+func (m *Metrics) observe(repository, operation string, start time.Time) {
+	m.repositoryLatency.WithLabelValues(repository, operation).Observe(time.Since(start).Seconds())
+}
+
+var blockedDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "", "blocked_identities"),
+	"Identities currently blocked, by kind.",
+	[]string{"kind"},
+	nil,
+)
+
+const blockedListingTTL = 30 * time.Second
+
+type blockedCollector struct {
+	repository entity.AdminRepository
+	ttl        time.Duration
+
+	mu        sync.Mutex
+	count     map[string]int
+	fetchedAt time.Time
+}
+
+func (c *blockedCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- blockedDesc
+}
+
+func (c *blockedCollector) Collect(ch chan<- prometheus.Metric) {
+	count, err := c.blocked()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(blockedDesc, err)
+		return
+	}
+
+	for kind, total := range count {
+		ch <- prometheus.MustNewConstMetric(blockedDesc, prometheus.GaugeValue, float64(total), kind)
+	}
+}
+
+// blocked counts the blocks by kind, a failed listing is not cached so the next scrape tries again
+func (c *blockedCollector) blocked() (map[string]int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.count != nil && time.Since(c.fetchedAt) < c.ttl {
+		return c.count, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	blocks, err := c.repository.ListBlocked(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	count := map[string]int{
+		entity.BlockKindIP:     0,
+		entity.BlockKindCIDR:   0,
+		entity.BlockKindApiKey: 0,
+	}
+	for _, block := range blocks {
+		count[block.Kind]++
+	}
+
+	c.count, c.fetchedAt = count, time.Now()
+	return count, nil
+}
@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/MatheusBenetti/rate-limiter/internal/entity/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockedCollectorReusesTheListing(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	repository := mock.NewMockAdminRepository(ctrl)
+	repository.EXPECT().ListBlocked(gomock.Any()).Return(nil, errors.New("dial tcp: connection refused"))
+	repository.EXPECT().ListBlocked(gomock.Any()).Return([]entity.Block{
+		{Kind: entity.BlockKindIP, Identity: "10.0.0.1"},
+		{Kind: entity.BlockKindCIDR, Identity: "10.1.0.0/16"},
+	}, nil).Times(1)
+
+	collector := &blockedCollector{repository: repository, ttl: time.Hour}
+
+	_, err := collector.blocked()
+	assert.Error(t, err)
+	assert.Equal(t, 3, testutil.CollectAndCount(collector), "a failed listing is tried again")
+	assert.Equal(t, 3, testutil.CollectAndCount(collector), "the listing is reused inside the TTL")
+
+	count, err := collector.blocked()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{
+		entity.BlockKindIP:     1,
+		entity.BlockKindCIDR:   1,
+		entity.BlockKindApiKey: 0,
+	}, count)
+}
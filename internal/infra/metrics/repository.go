@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+)
+
+// IPRepository measures the latency of every operation of the repository
+func (m *Metrics) IPRepository(next entity.IPRepository) entity.IPRepository {
+	return &commonRepository{next: next, metrics: m, name: "ip"}
+}
+
+// ApiKeyRepository measures the latency of every operation of the repository
+func (m *Metrics) ApiKeyRepository(next entity.ApiKeyRepository) entity.ApiKeyRepository {
+	return &apiKeyRepository{
+		commonRepository: commonRepository{next: next, metrics: m, name: "api_key"},
+		next:             next,
+	}
+}
+
+// SemaphoreRepository measures the latency of every operation of the repository
+func (m *Metrics) SemaphoreRepository(next entity.SemaphoreRepository) entity.SemaphoreRepository {
+	return &semaphoreRepository{next: next, metrics: m}
+}
+
+type commonRepository struct {
+	next    entity.IPRepository
+	metrics *Metrics
+	name    string
+}
+
+func (r *commonRepository) UpsertRequest(ctx context.Context, key string, rl *entity.RateLimiter) error {
+	defer r.metrics.observe(r.name, "UpsertRequest", time.Now())
+	return r.next.UpsertRequest(ctx, key, rl)
+}
+
+func (r *commonRepository) UpdateRequest(ctx context.Context, key string, update func(rl *entity.RateLimiter) bool) error {
+	defer r.metrics.observe(r.name, "UpdateRequest", time.Now())
+	return r.next.UpdateRequest(ctx, key, update)
+}
+
+func (r *commonRepository) SaveBlockedDuration(ctx context.Context, key string, blockedDuration int64) error {
+	defer r.metrics.observe(r.name, "SaveBlockedDuration", time.Now())
+	return r.next.SaveBlockedDuration(ctx, key, blockedDuration)
+}
+
+func (r *commonRepository) IncrOffense(ctx context.Context, key string, decay int64) (int64, error) {
+	defer r.metrics.observe(r.name, "IncrOffense", time.Now())
+	return r.next.IncrOffense(ctx, key, decay)
+}
+
+func (r *commonRepository) GetBlockedDuration(ctx context.Context, key string) (string, error) {
+	defer r.metrics.observe(r.name, "GetBlockedDuration", time.Now())
+	return r.next.GetBlockedDuration(ctx, key)
+}
+
+func (r *commonRepository) GetRequest(ctx context.Context, key string) (*entity.RateLimiter, error) {
+	defer r.metrics.observe(r.name, "GetRequest", time.Now())
+	return r.next.GetRequest(ctx, key)
+}
+
+func (r *commonRepository) DeleteRequest(ctx context.Context, key string) error {
+	defer r.metrics.observe(r.name, "DeleteRequest", time.Now())
+	return r.next.DeleteRequest(ctx, key)
+}
+
+type apiKeyRepository struct {
+	commonRepository
+	next entity.ApiKeyRepository
+}
+
+func (r *apiKeyRepository) Save(ctx context.Context, key *entity.ApiKey) (string, error) {
+	defer r.metrics.observe(r.name, "Save", time.Now())
+	return r.next.Save(ctx, key)
+}
+
+func (r *apiKeyRepository) Get(ctx context.Context, value string) (*entity.ApiKey, error) {
+	defer r.metrics.observe(r.name, "Get", time.Now())
+	return r.next.Get(ctx, value)
+}
+
+type semaphoreRepository struct {
+	next    entity.SemaphoreRepository
+	metrics *Metrics
+}
+
+func (r *semaphoreRepository) Acquire(ctx context.Context, lease *entity.Lease, limit int) (bool, error) {
+	defer r.metrics.observe("semaphore", "Acquire", time.Now())
+	return r.next.Acquire(ctx, lease, limit)
+}
+
+func (r *semaphoreRepository) Refresh(ctx context.Context, lease *entity.Lease) (bool, error) {
+	defer r.metrics.observe("semaphore", "Refresh", time.Now())
+	return r.next.Refresh(ctx, lease)
+}
+
+func (r *semaphoreRepository) Release(ctx context.Context, lease *entity.Lease) error {
+	defer r.metrics.observe("semaphore", "Release", time.Now())
+	return r.next.Release(ctx, lease)
+}
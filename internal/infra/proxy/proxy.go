@@ -0,0 +1,99 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/MatheusBenetti/rate-limiter/config"
+)
+
+// Proxy forwards each request to the upstream with the longest prefix matching its path, prefixes match
+// whole path segments so /api takes /api and /api/users but not /apix.
+// Upstreams can report the cost of a request with the X-RateLimit-Cost response header
+type Proxy struct {
+	upstreams []upstream
+}
+
+type upstream struct {
+	prefix  string
+	handler *httputil.ReverseProxy
+}
+
+func NewProxy(upstreams map[string]config.UpstreamValues) (*Proxy, error) {
+	p := &Proxy{upstreams: make([]upstream, 0, len(upstreams))}
+	for prefix, values := range upstreams {
+		target, err := url.Parse(values.URL)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing the upstream of %s: %w", prefix, err)
+		}
+
+		p.upstreams = append(p.upstreams, upstream{
+			prefix:  prefix,
+			handler: newReverseProxy(prefix, target, values),
+		})
+	}
+	sort.Slice(p.upstreams, func(i, j int) bool {
+		return len(p.upstreams[i].prefix) > len(p.upstreams[j].prefix)
+	})
+
+	return p, nil
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// prefixes are lowercased by viper, so they are matched against the lowercased path
+	path := strings.ToLower(r.URL.Path)
+	for _, u := range p.upstreams {
+		if matches(path, u.prefix) {
+			u.handler.ServeHTTP(w, r)
+			return
+		}
+	}
+
+	http.NotFound(w, r)
+}
+
+func matches(path, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+func newReverseProxy(prefix string, target *url.URL, values config.UpstreamValues) *httputil.ReverseProxy {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = time.Duration(values.Timeout) * time.Millisecond
+
+	return &httputil.ReverseProxy{
+		Transport: transport,
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			if stripped := strings.TrimSuffix(prefix, "/"); values.StripPrefix && len(pr.Out.URL.Path) >= len(stripped) {
+				pr.Out.URL.Path = "/" + strings.TrimLeft(pr.Out.URL.Path[len(stripped):], "/")
+				pr.Out.URL.RawPath = ""
+			}
+			pr.SetURL(target)
+			pr.SetXForwarded()
+
+			for _, header := range values.RemoveHeaders {
+				pr.Out.Header.Del(header)
+			}
+			for header, value := range values.SetHeaders {
+				pr.Out.Header.Set(header, value)
+			}
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			slog.ErrorContext(r.Context(), "error forwarding request to upstream", "upstream", target.Host, "error", err)
+			status := http.StatusBadGateway
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				status = http.StatusGatewayTimeout
+			}
+			http.Error(w, http.StatusText(status), status)
+		},
+	}
+}
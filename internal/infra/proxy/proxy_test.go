@@ -0,0 +1,126 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MatheusBenetti/rate-limiter/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newUpstream answers with its name and echoes the path and the headers it received
+func newUpstream(t *testing.T, name string) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Upstream", name)
+		w.Header().Set("X-Path", r.URL.Path)
+		w.Header().Set("X-Env", r.Header.Get("X-Env"))
+		w.Header().Set("X-Authorization", r.Header.Get("Authorization"))
+		w.Header().Set("X-Got-Forwarded-For", r.Header.Get("X-Forwarded-For"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func forward(p *Proxy, path string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestProxyRouting(t *testing.T) {
+	api := newUpstream(t, "api")
+	admin := newUpstream(t, "admin")
+	p, err := NewProxy(map[string]config.UpstreamValues{
+		"/api":       {URL: api.URL},
+		"/api/admin": {URL: admin.URL},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		path             string
+		expectedStatus   int
+		expectedUpstream string
+	}{
+		{path: "/api", expectedStatus: http.StatusOK, expectedUpstream: "api"},
+		{path: "/api/users", expectedStatus: http.StatusOK, expectedUpstream: "api"},
+		{path: "/API/users", expectedStatus: http.StatusOK, expectedUpstream: "api"},
+		{path: "/api/admin/users", expectedStatus: http.StatusOK, expectedUpstream: "admin"},
+		{path: "/api/administrator", expectedStatus: http.StatusOK, expectedUpstream: "api"},
+		{path: "/apix", expectedStatus: http.StatusNotFound},
+		{path: "/other", expectedStatus: http.StatusNotFound},
+	}
+
+	for i := 0; i < len(tests); i++ {
+		t.Run(tests[i].path, func(t *testing.T) {
+			rec := forward(p, tests[i].path, nil)
+			assert.Equal(t, tests[i].expectedStatus, rec.Code)
+			assert.Equal(t, tests[i].expectedUpstream, rec.Header().Get("X-Upstream"))
+		})
+	}
+}
+
+func TestProxyStripPrefix(t *testing.T) {
+	upstream := newUpstream(t, "svc")
+	p, err := NewProxy(map[string]config.UpstreamValues{
+		"/svc/": {URL: upstream.URL, StripPrefix: true},
+		"/keep": {URL: upstream.URL},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		path         string
+		expectedPath string
+	}{
+		{path: "/svc/users/1", expectedPath: "/users/1"},
+		{path: "/svc/", expectedPath: "/"},
+		{path: "/svc", expectedPath: "/"},
+		{path: "/keep/users", expectedPath: "/keep/users"},
+	}
+
+	for i := 0; i < len(tests); i++ {
+		t.Run(tests[i].path, func(t *testing.T) {
+			rec := forward(p, tests[i].path, nil)
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, tests[i].expectedPath, rec.Header().Get("X-Path"))
+		})
+	}
+}
+
+func TestProxyRewritesHeaders(t *testing.T) {
+	upstream := newUpstream(t, "svc")
+	p, err := NewProxy(map[string]config.UpstreamValues{
+		"/svc": {
+			URL:           upstream.URL,
+			SetHeaders:    map[string]string{"X-Env": "prod"},
+			RemoveHeaders: []string{"Authorization"},
+		},
+	})
+	require.NoError(t, err)
+
+	rec := forward(p, "/svc", map[string]string{"Authorization": "Bearer secret", "X-Env": "dev"})
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "prod", rec.Header().Get("X-Env"))
+	assert.Empty(t, rec.Header().Get("X-Authorization"))
+	assert.Equal(t, "10.0.0.1", rec.Header().Get("X-Got-Forwarded-For"))
+}
+
+func TestProxyUnavailableUpstream(t *testing.T) {
+	upstream := newUpstream(t, "svc")
+	upstream.Close()
+	p, err := NewProxy(map[string]config.UpstreamValues{"/svc": {URL: upstream.URL}})
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusBadGateway, forward(p, "/svc", nil).Code)
+}
@@ -0,0 +1,209 @@
+package rls
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/MatheusBenetti/rate-limiter/config"
+	"github.com/MatheusBenetti/rate-limiter/internal/dto"
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/metrics"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/tracing"
+	"github.com/MatheusBenetti/rate-limiter/internal/usecase"
+	ratelimitv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/common/ratelimit/v3"
+	rlsv3 "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// strategy labels the decisions of the rate limit service in the metrics and logs
+const strategy = "rls"
+
+// Storage builds the repositories the descriptors are limited with
+type Storage interface {
+	IPRepository(namespace string) entity.IPRepository
+}
+
+// Server implements the Envoy rate limit service v3, evaluating each descriptor of a request
+// by the first rule of its domain that matches it
+type Server struct {
+	rlsv3.UnimplementedRateLimitServiceServer
+	storage Storage
+	config  *config.Store
+	metrics *metrics.Metrics
+}
+
+func NewServer(storage Storage, config *config.Store, metrics *metrics.Metrics) *Server {
+	return &Server{storage: storage, config: config, metrics: metrics}
+}
+
+func (s *Server) ShouldRateLimit(ctx context.Context, req *rlsv3.RateLimitRequest) (*rlsv3.RateLimitResponse, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "rls.ShouldRateLimit",
+		trace.WithAttributes(tracing.StrategyKey.String(strategy), tracing.RouteKey.String(req.GetDomain())),
+	)
+	defer span.End()
+
+	cost := int(req.GetHitsAddend())
+	if cost == 0 {
+		cost = 1
+	}
+
+	// domains are lowercased by viper
+	domain := strings.ToLower(req.GetDomain())
+	rules := s.config.Current().RLS.Domains[domain]
+	response := &rlsv3.RateLimitResponse{OverallCode: rlsv3.RateLimitResponse_OK}
+	for _, descriptor := range req.GetDescriptors() {
+		descriptorStatus, err := s.evaluate(ctx, domain, rules, descriptor, cost)
+		if err != nil {
+			tracing.End(span, err)
+			return nil, err
+		}
+		if descriptorStatus.Code == rlsv3.RateLimitResponse_OVER_LIMIT {
+			response.OverallCode = rlsv3.RateLimitResponse_OVER_LIMIT
+		}
+		response.Statuses = append(response.Statuses, descriptorStatus)
+	}
+
+	span.SetAttributes(tracing.AllowedKey.Bool(response.OverallCode == rlsv3.RateLimitResponse_OK))
+	return response, nil
+}
+
+func (s *Server) evaluate(
+	ctx context.Context,
+	domain string,
+	rules []config.DescriptorValues,
+	descriptor *ratelimitv3.RateLimitDescriptor,
+	cost int,
+) (*rlsv3.RateLimitResponse_DescriptorStatus, error) {
+	rule, ok := match(rules, descriptor)
+	if !ok {
+		return &rlsv3.RateLimitResponse_DescriptorStatus{Code: rlsv3.RateLimitResponse_OK}, nil
+	}
+
+	ipDB := s.storage.IPRepository(fmt.Sprintf("rls:%s", domain))
+	ipReq := usecase.NewRegisterIPPolicyUseCase(ipDB, rule.Policy)
+	execute, execErr := ipReq.Execute(ctx, dto.IpReq{
+		IP:        identity(rule, descriptor),
+		TimeAdded: time.Now(),
+		Cost:      cost,
+	})
+
+	descriptorStatus := &rlsv3.RateLimitResponse_DescriptorStatus{
+		Code:         rlsv3.RateLimitResponse_OK,
+		CurrentLimit: currentLimit(rule),
+	}
+	switch {
+	case errors.Is(execErr, entity.ErrIpAmountReq):
+		descriptorStatus.Code = rlsv3.RateLimitResponse_OVER_LIMIT
+		s.metrics.Decision(strategy, rule.Name, domain, metrics.DecisionBlocked)
+	case execErr != nil:
+		s.metrics.Decision(strategy, rule.Name, domain, metrics.DecisionUnavailable)
+		slog.ErrorContext(ctx, "error evaluating rate limit descriptor", "domain", domain, "descriptor", rule.Name, "error", execErr)
+		return nil, status.Error(codes.Unavailable, execErr.Error())
+	case !execute.Allow:
+		descriptorStatus.Code = rlsv3.RateLimitResponse_OVER_LIMIT
+		s.metrics.Decision(strategy, rule.Name, domain, metrics.DecisionLimited)
+	default:
+		descriptorStatus.LimitRemaining = uint32(execute.Remaining)
+		s.metrics.Decision(strategy, rule.Name, domain, metrics.DecisionAllowed)
+	}
+	if descriptorStatus.Code == rlsv3.RateLimitResponse_OVER_LIMIT {
+		slog.InfoContext(ctx, "too many requests", "domain", domain, "descriptor", rule.Name)
+	}
+
+	return descriptorStatus, nil
+}
+
+// match returns the first rule whose entries match the descriptor ones in order
+func match(rules []config.DescriptorValues, descriptor *ratelimitv3.RateLimitDescriptor) (config.DescriptorValues, bool) {
+	entries := descriptor.GetEntries()
+	for _, rule := range rules {
+		if len(rule.Entries) != len(entries) {
+			continue
+		}
+
+		matched := true
+		for i, entry := range rule.Entries {
+			if entry.Key != entries[i].GetKey() || (entry.Value != "" && entry.Value != entries[i].GetValue()) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return rule, true
+		}
+	}
+
+	return config.DescriptorValues{}, false
+}
+
+// identity names the counter of a descriptor, each value of the entries is limited apart
+func identity(rule config.DescriptorValues, descriptor *ratelimitv3.RateLimitDescriptor) string {
+	pairs := make([]string, 0, len(descriptor.GetEntries()))
+	for _, entry := range descriptor.GetEntries() {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", entry.GetKey(), entry.GetValue()))
+	}
+
+	return fmt.Sprintf("%s:%s", rule.Name, strings.Join(pairs, ","))
+}
+
+// currentLimit reports the policy of the rule in the unit that matches its time window, windows
+// that are not a whole unit are reported as UNKNOWN
+func currentLimit(rule config.DescriptorValues) *rlsv3.RateLimitResponse_RateLimit {
+	units := map[int64]rlsv3.RateLimitResponse_RateLimit_Unit{
+		1:     rlsv3.RateLimitResponse_RateLimit_SECOND,
+		60:    rlsv3.RateLimitResponse_RateLimit_MINUTE,
+		3600:  rlsv3.RateLimitResponse_RateLimit_HOUR,
+		86400: rlsv3.RateLimitResponse_RateLimit_DAY,
+	}
+
+	return &rlsv3.RateLimitResponse_RateLimit{
+		Name:            rule.Name,
+		RequestsPerUnit: uint32(rule.Policy.MaxReq),
+		Unit:            units[rule.Policy.TimeWindow],
+	}
+}
+
+// Start serves the rate limit service on port until ctx is done and then stops gracefully, waiting
+// for at most timeout before dropping the calls still running
+func (s *Server) Start(ctx context.Context, port string, timeout time.Duration) error {
+	listener, err := net.Listen("tcp", fmt.Sprintf("0.0.0.0:%s", port))
+	if err != nil {
+		return fmt.Errorf("error listening on the rls port: %w", err)
+	}
+
+	server := grpc.NewServer()
+	rlsv3.RegisterRateLimitServiceServer(server, s)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("error starting the rls server: %w", err)
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down rls server", "timeout", timeout)
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(timeout):
+		server.Stop()
+	}
+
+	return nil
+}
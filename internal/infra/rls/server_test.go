@@ -0,0 +1,182 @@
+package rls
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/MatheusBenetti/rate-limiter/config"
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/database"
+	"github.com/alicebob/miniredis/v2"
+	ratelimitv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/common/ratelimit/v3"
+	rlsv3 "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type redisStorage struct {
+	client redis.UniversalClient
+}
+
+func (s redisStorage) IPRepository(namespace string) entity.IPRepository {
+	return database.NewIPRedisWithNamespace(s.client, namespace)
+}
+
+// newTestClient serves the rate limit service over an in-memory connection backed by an in-memory redis
+func newTestClient(t *testing.T, domains map[string][]config.DescriptorValues) (rlsv3.RateLimitServiceClient, *miniredis.Miniredis) {
+	t.Helper()
+
+	redisServer := miniredis.RunT(t)
+	cfg := &config.Config{}
+	cfg.RLS.Domains = domains
+	storage := redisStorage{client: redis.NewClient(&redis.Options{Addr: redisServer.Addr()})}
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	rlsv3.RegisterRateLimitServiceServer(server, NewServer(storage, config.NewStore(cfg), nil))
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return rlsv3.NewRateLimitServiceClient(conn), redisServer
+}
+
+func descriptor(pairs ...string) *ratelimitv3.RateLimitDescriptor {
+	d := &ratelimitv3.RateLimitDescriptor{}
+	for i := 0; i < len(pairs); i += 2 {
+		d.Entries = append(d.Entries, &ratelimitv3.RateLimitDescriptor_Entry{Key: pairs[i], Value: pairs[i+1]})
+	}
+	return d
+}
+
+func policy(maxReq int, timeWindow int64) config.LimitValues {
+	return config.LimitValues{MaxReq: maxReq, TimeWindow: timeWindow, BlockDuration: 60}
+}
+
+func TestShouldRateLimitMatchesDescriptors(t *testing.T) {
+	client, _ := newTestClient(t, map[string][]config.DescriptorValues{
+		"edge": {
+			{Name: "login", Entries: []config.EntryValues{{Key: "path", Value: "/login"}}, Policy: policy(5, 60)},
+			{Name: "path", Entries: []config.EntryValues{{Key: "path"}}, Policy: policy(10, 60)},
+			{Name: "client_path", Entries: []config.EntryValues{{Key: "remote_address"}, {Key: "path"}}, Policy: policy(20, 60)},
+		},
+	})
+
+	tests := []struct {
+		name         string
+		descriptor   *ratelimitv3.RateLimitDescriptor
+		expectedRule string
+	}{
+		{name: "value match wins by order", descriptor: descriptor("path", "/login"), expectedRule: "login"},
+		{name: "entry without value matches any value", descriptor: descriptor("path", "/orders"), expectedRule: "path"},
+		{name: "entries in order", descriptor: descriptor("remote_address", "10.0.0.1", "path", "/orders"), expectedRule: "client_path"},
+		{name: "entries out of order", descriptor: descriptor("path", "/orders", "remote_address", "10.0.0.1")},
+		{name: "unknown key", descriptor: descriptor("user", "1")},
+	}
+
+	for i := 0; i < len(tests); i++ {
+		t.Run(tests[i].name, func(t *testing.T) {
+			response, err := client.ShouldRateLimit(context.Background(), &rlsv3.RateLimitRequest{
+				Domain:      "edge",
+				Descriptors: []*ratelimitv3.RateLimitDescriptor{tests[i].descriptor},
+			})
+			require.NoError(t, err)
+			require.Len(t, response.GetStatuses(), 1)
+			assert.Equal(t, rlsv3.RateLimitResponse_OK, response.GetOverallCode())
+			assert.Equal(t, tests[i].expectedRule, response.GetStatuses()[0].GetCurrentLimit().GetName())
+		})
+	}
+}
+
+func TestShouldRateLimitAggregatesOverLimit(t *testing.T) {
+	client, _ := newTestClient(t, map[string][]config.DescriptorValues{
+		"edge": {
+			{Name: "strict", Entries: []config.EntryValues{{Key: "user"}}, Policy: policy(1, 60)},
+			{Name: "loose", Entries: []config.EntryValues{{Key: "path"}}, Policy: policy(10, 60)},
+		},
+	})
+	request := func() *rlsv3.RateLimitResponse {
+		response, err := client.ShouldRateLimit(context.Background(), &rlsv3.RateLimitRequest{
+			Domain:      "edge",
+			Descriptors: []*ratelimitv3.RateLimitDescriptor{descriptor("user", "1"), descriptor("path", "/orders")},
+		})
+		require.NoError(t, err)
+		return response
+	}
+
+	first := request()
+	assert.Equal(t, rlsv3.RateLimitResponse_OK, first.GetOverallCode())
+	assert.Equal(t, uint32(0), first.GetStatuses()[0].GetLimitRemaining())
+	assert.Equal(t, uint32(9), first.GetStatuses()[1].GetLimitRemaining())
+
+	second := request()
+	assert.Equal(t, rlsv3.RateLimitResponse_OVER_LIMIT, second.GetOverallCode(), "one descriptor over the limit denies the request")
+	assert.Equal(t, rlsv3.RateLimitResponse_OVER_LIMIT, second.GetStatuses()[0].GetCode())
+	assert.Equal(t, rlsv3.RateLimitResponse_OK, second.GetStatuses()[1].GetCode())
+	assert.Equal(t, uint32(8), second.GetStatuses()[1].GetLimitRemaining())
+}
+
+func TestShouldRateLimitCurrentLimitUnits(t *testing.T) {
+	tests := []struct {
+		timeWindow   int64
+		expectedUnit rlsv3.RateLimitResponse_RateLimit_Unit
+	}{
+		{timeWindow: 1, expectedUnit: rlsv3.RateLimitResponse_RateLimit_SECOND},
+		{timeWindow: 60, expectedUnit: rlsv3.RateLimitResponse_RateLimit_MINUTE},
+		{timeWindow: 3600, expectedUnit: rlsv3.RateLimitResponse_RateLimit_HOUR},
+		{timeWindow: 86400, expectedUnit: rlsv3.RateLimitResponse_RateLimit_DAY},
+		{timeWindow: 30, expectedUnit: rlsv3.RateLimitResponse_RateLimit_UNKNOWN},
+	}
+
+	for i := 0; i < len(tests); i++ {
+		t.Run(tests[i].expectedUnit.String(), func(t *testing.T) {
+			client, _ := newTestClient(t, map[string][]config.DescriptorValues{
+				"edge": {{Name: "path", Entries: []config.EntryValues{{Key: "path"}}, Policy: policy(7, tests[i].timeWindow)}},
+			})
+
+			response, err := client.ShouldRateLimit(context.Background(), &rlsv3.RateLimitRequest{
+				Domain:      "edge",
+				Descriptors: []*ratelimitv3.RateLimitDescriptor{descriptor("path", "/orders")},
+			})
+			require.NoError(t, err)
+			limit := response.GetStatuses()[0].GetCurrentLimit()
+			assert.Equal(t, tests[i].expectedUnit, limit.GetUnit())
+			assert.Equal(t, uint32(7), limit.GetRequestsPerUnit())
+		})
+	}
+}
+
+func TestShouldRateLimitUnknownDomainAndUnavailableRedis(t *testing.T) {
+	client, redisServer := newTestClient(t, map[string][]config.DescriptorValues{
+		"edge": {{Name: "path", Entries: []config.EntryValues{{Key: "path"}}, Policy: policy(10, 60)}},
+	})
+
+	response, err := client.ShouldRateLimit(context.Background(), &rlsv3.RateLimitRequest{
+		Domain:      "other",
+		Descriptors: []*ratelimitv3.RateLimitDescriptor{descriptor("path", "/orders")},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, rlsv3.RateLimitResponse_OK, response.GetOverallCode())
+
+	redisServer.Close()
+	_, err = client.ShouldRateLimit(context.Background(), &rlsv3.RateLimitRequest{
+		Domain:      "edge",
+		Descriptors: []*ratelimitv3.RateLimitDescriptor{descriptor("path", "/orders")},
+	})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
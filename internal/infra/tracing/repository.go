@@ -0,0 +1,131 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// IPRepository opens a span for every operation of the repository
+func IPRepository(next entity.IPRepository) entity.IPRepository {
+	return &commonRepository{next: next, name: "ip"}
+}
+
+// ApiKeyRepository opens a span for every operation of the repository
+func ApiKeyRepository(next entity.ApiKeyRepository) entity.ApiKeyRepository {
+	return &apiKeyRepository{
+		commonRepository: commonRepository{next: next, name: "api_key"},
+		next:             next,
+	}
+}
+
+// SemaphoreRepository opens a span for every operation of the repository
+func SemaphoreRepository(next entity.SemaphoreRepository) entity.SemaphoreRepository {
+	return &semaphoreRepository{next: next}
+}
+
+func startOperation(ctx context.Context, repository, operation string) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, repository+"."+operation,
+		trace.WithAttributes(attribute.String("ratelimit.repository", repository)),
+	)
+}
+
+type commonRepository struct {
+	next entity.IPRepository
+	name string
+}
+
+func (r *commonRepository) UpsertRequest(ctx context.Context, key string, rl *entity.RateLimiter) error {
+	ctx, span := startOperation(ctx, r.name, "UpsertRequest")
+	err := r.next.UpsertRequest(ctx, key, rl)
+	End(span, err)
+	return err
+}
+
+func (r *commonRepository) UpdateRequest(ctx context.Context, key string, update func(rl *entity.RateLimiter) bool) error {
+	ctx, span := startOperation(ctx, r.name, "UpdateRequest")
+	err := r.next.UpdateRequest(ctx, key, update)
+	End(span, err)
+	return err
+}
+
+func (r *commonRepository) SaveBlockedDuration(ctx context.Context, key string, blockedDuration int64) error {
+	ctx, span := startOperation(ctx, r.name, "SaveBlockedDuration")
+	err := r.next.SaveBlockedDuration(ctx, key, blockedDuration)
+	End(span, err)
+	return err
+}
+
+func (r *commonRepository) IncrOffense(ctx context.Context, key string, decay int64) (int64, error) {
+	ctx, span := startOperation(ctx, r.name, "IncrOffense")
+	offenses, err := r.next.IncrOffense(ctx, key, decay)
+	End(span, err)
+	return offenses, err
+}
+
+func (r *commonRepository) GetBlockedDuration(ctx context.Context, key string) (string, error) {
+	ctx, span := startOperation(ctx, r.name, "GetBlockedDuration")
+	status, err := r.next.GetBlockedDuration(ctx, key)
+	End(span, err)
+	return status, err
+}
+
+func (r *commonRepository) GetRequest(ctx context.Context, key string) (*entity.RateLimiter, error) {
+	ctx, span := startOperation(ctx, r.name, "GetRequest")
+	rl, err := r.next.GetRequest(ctx, key)
+	End(span, err)
+	return rl, err
+}
+
+func (r *commonRepository) DeleteRequest(ctx context.Context, key string) error {
+	ctx, span := startOperation(ctx, r.name, "DeleteRequest")
+	err := r.next.DeleteRequest(ctx, key)
+	End(span, err)
+	return err
+}
+
+type apiKeyRepository struct {
+	commonRepository
+	next entity.ApiKeyRepository
+}
+
+func (r *apiKeyRepository) Save(ctx context.Context, key *entity.ApiKey) (string, error) {
+	ctx, span := startOperation(ctx, r.name, "Save")
+	value, err := r.next.Save(ctx, key)
+	End(span, err)
+	return value, err
+}
+
+func (r *apiKeyRepository) Get(ctx context.Context, value string) (*entity.ApiKey, error) {
+	ctx, span := startOperation(ctx, r.name, "Get")
+	apiKey, err := r.next.Get(ctx, value)
+	End(span, err)
+	return apiKey, err
+}
+
+type semaphoreRepository struct {
+	next entity.SemaphoreRepository
+}
+
+func (r *semaphoreRepository) Acquire(ctx context.Context, lease *entity.Lease, limit int) (bool, error) {
+	ctx, span := startOperation(ctx, "semaphore", "Acquire")
+	acquired, err := r.next.Acquire(ctx, lease, limit)
+	End(span, err)
+	return acquired, err
+}
+
+func (r *semaphoreRepository) Refresh(ctx context.Context, lease *entity.Lease) (bool, error) {
+	ctx, span := startOperation(ctx, "semaphore", "Refresh")
+	refreshed, err := r.next.Refresh(ctx, lease)
+	End(span, err)
+	return refreshed, err
+}
+
+func (r *semaphoreRepository) Release(ctx context.Context, lease *entity.Lease) error {
+	ctx, span := startOperation(ctx, "semaphore", "Release")
+	err := r.next.Release(ctx, lease)
+	End(span, err)
+	return err
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/logger"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// AccessLog logs every served request through slog. The client is logged by the hash of its identity, the same
+// one the limiter logs, so the IP and the API key never appear in clear
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+		defer func() {
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			slog.InfoContext(r.Context(), "request served",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", status,
+				"bytes", ww.BytesWritten(),
+				"duration", time.Since(start),
+				logger.IdentityHashKey, logger.HashIdentity(identityKey(r, r.Header.Get(entity.ApiKeyHeader))),
+			)
+		}()
+
+		next.ServeHTTP(ww, r)
+	})
+}
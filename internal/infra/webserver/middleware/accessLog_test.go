@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/MatheusBenetti/rate-limiter/config"
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessLogHidesTheClient(t *testing.T) {
+	var out bytes.Buffer
+	log, err := logger.New(&out, config.Log{Format: config.LogFormatJSON})
+	require.NoError(t, err)
+	previous := slog.Default()
+	slog.SetDefault(log)
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	handler := AccessLog(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	serve(handler, "/", nil)
+	serve(handler, "/", map[string]string{entity.ApiKeyHeader: "secret-key"})
+
+	assert.Contains(t, out.String(), `"status":418`)
+	assert.Contains(t, out.String(), `"identity_hash":"`+logger.HashIdentity("ip_10.0.0.1")+`"`)
+	assert.Contains(t, out.String(), `"identity_hash":"`+logger.HashIdentity("api-key_secret-key")+`"`)
+	assert.NotContains(t, out.String(), "10.0.0.1")
+	assert.NotContains(t, out.String(), "secret-key")
+}
@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/MatheusBenetti/rate-limiter/config"
+	"github.com/MatheusBenetti/rate-limiter/internal/dto"
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/metrics"
+)
+
+// maxLatencySamples bounds the latencies kept in an interval, past it they are sampled
+const maxLatencySamples = 10000
+
+// Adaptive measures the responses of the limited handlers of this replica and, once every interval,
+// adapts the factor the by_ip and API key limits are scaled by. A nil Adaptive never scales the limits
+type Adaptive struct {
+	config    *config.Store
+	metrics   *metrics.Metrics
+	lock      sync.Mutex
+	aimd      *entity.AIMD
+	latencies []time.Duration
+	responses int
+	errors    int
+	state     dto.AdaptiveOutput
+}
+
+func NewAdaptive(config *config.Store, metrics *metrics.Metrics) *Adaptive {
+	cfg := config.Current()
+	return &Adaptive{
+		config:  config,
+		metrics: metrics,
+		aimd:    entity.NewAIMD(0, 0, 0),
+		state:   dto.AdaptiveOutput{Factor: 1, ByIpLimit: cfg.RateLimiter.ByIp.MaxReq, Healthy: true},
+	}
+}
+
+// Observe records a response of a limited handler
+func (a *Adaptive) Observe(latency time.Duration, status int) {
+	if a == nil {
+		return
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.responses++
+	if status >= http.StatusInternalServerError {
+		a.errors++
+	}
+	if len(a.latencies) < maxLatencySamples {
+		a.latencies = append(a.latencies, latency)
+	} else if i := rand.IntN(a.responses); i < maxLatencySamples {
+		a.latencies[i] = latency
+	}
+}
+
+// Factor returns the factor the limits are scaled by, one while the handlers are healthy
+func (a *Adaptive) Factor() float64 {
+	if a == nil {
+		return 1
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.aimd.Factor()
+}
+
+func (a *Adaptive) State() dto.AdaptiveOutput {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.state
+}
+
+// Start adapts the factor every interval of the startup configuration until ctx is done,
+// the thresholds and the steps are read again on every interval
+func (a *Adaptive) Start(ctx context.Context) error {
+	ticker := time.NewTicker(time.Duration(a.config.Current().RateLimiter.Adaptive.Interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			a.adjust()
+		}
+	}
+}
+
+func (a *Adaptive) adjust() {
+	cfg := a.config.Current()
+	values := cfg.RateLimiter.Adaptive
+
+	a.lock.Lock()
+	p99 := entity.Percentile(a.latencies, 0.99)
+	errorRatio := 0.0
+	if a.responses > 0 {
+		errorRatio = float64(a.errors) / float64(a.responses)
+	}
+	healthy := values.Healthy(a.responses, p99, errorRatio)
+	previous := a.aimd.Factor()
+	a.aimd.Decrease, a.aimd.Increase, a.aimd.MinFactor = values.DecreaseBy(), values.IncreaseBy(), values.Floor()
+	factor := a.aimd.Adjust(healthy)
+	state := dto.AdaptiveOutput{
+		Factor:     factor,
+		ByIpLimit:  entity.ScaleLimit(cfg.RateLimiter.ByIp.MaxReq, factor),
+		Healthy:    healthy,
+		Samples:    a.responses,
+		P99:        p99.Milliseconds(),
+		ErrorRatio: errorRatio,
+	}
+	a.state = state
+	a.latencies, a.responses, a.errors = a.latencies[:0], 0, 0
+	a.lock.Unlock()
+
+	a.metrics.Adaptive(factor, state.ByIpLimit, p99, errorRatio)
+	if factor != previous {
+		slog.Info("adaptive limits changed", "factor", factor, "healthy", healthy, "p99", p99, "error_ratio", errorRatio)
+	}
+}
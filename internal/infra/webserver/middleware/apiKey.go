@@ -2,45 +2,85 @@ package middleware
 
 import (
 	"errors"
-	"log"
+	"log/slog"
 	"net/http"
 	"time"
 
+	"github.com/MatheusBenetti/rate-limiter/config"
 	"github.com/MatheusBenetti/rate-limiter/internal/dto"
 	"github.com/MatheusBenetti/rate-limiter/internal/entity"
-	"github.com/MatheusBenetti/rate-limiter/internal/infra/database"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/tracing"
 	"github.com/MatheusBenetti/rate-limiter/internal/usecase"
-	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type APIKeyMiddleware struct {
-	RedisClient *redis.Client
-	ApiKey      string
+	Storage Storage
+	Config  *config.Config
+	ApiKey  string
+	Cost    int
+	// Factor scales the limit of the key, set by the adaptive limits
+	Factor float64
 }
 
 func (tk *APIKeyMiddleware) Execute(w http.ResponseWriter, r *http.Request) error {
-	tkDB := database.NewAPIKeyRedis(tk.RedisClient)
-	tkReq := usecase.NewRegisterAPIKeyUseCase(tkDB)
+	tkDB := tk.Storage.ApiKeyRepository()
+	tkReq := usecase.NewRegisterAPIKeyUseCase(tkDB, tk.Config).WithLimitFactor(tk.Factor)
 	execute, execErr := tkReq.Execute(r.Context(), dto.ApiKeyReq{
 		Value:     tk.ApiKey,
 		TimeAdded: time.Now(),
+		Cost:      tk.Cost,
 	})
 	if errors.Is(execErr, entity.ErrApiKeyAmountReq) {
-		log.Printf("Error executing ErrRateLimiterMaxRequests: %s\n", execErr.Error())
+		w.Header().Set(entity.RemainingHeader, "0")
 		http.Error(w, execErr.Error(), http.StatusTooManyRequests)
 		return execErr
 	}
-	if execErr != nil {
-		log.Printf("Error executing NewRegisterAPIKeyUseCase: %s\n", execErr.Error())
-		http.Error(w, execErr.Error(), http.StatusInternalServerError)
+	if errors.Is(execErr, entity.ErrUnknownApiKey) {
+		http.Error(w, execErr.Error(), http.StatusUnauthorized)
 		return execErr
 	}
+	if execErr != nil {
+		slog.ErrorContext(r.Context(), "error executing NewRegisterAPIKeyUseCase", "error", execErr)
+		return undecided(w, execErr)
+	}
 
+	setLimitHeaders(w, execute.Limit, execute.Remaining)
 	if !execute.Allow {
-		log.Printf("Too many request: %s\n", entity.ErrApiKeyAmountReq.Error())
 		http.Error(w, entity.ErrApiKeyAmountReq.Error(), http.StatusTooManyRequests)
-		return errors.New("too many request")
+		return errTooManyRequests
 	}
 
+	trace.SpanFromContext(r.Context()).SetAttributes(tracing.RemainingKey.Int(execute.Remaining))
 	return nil
 }
+
+func (tk *APIKeyMiddleware) Charge(r *http.Request, cost int) error {
+	tkDB := tk.Storage.ApiKeyRepository()
+	tkReq := usecase.NewRegisterAPIKeyUseCase(tkDB, tk.Config).WithLimitFactor(tk.Factor)
+	return tkReq.Charge(r.Context(), dto.ApiKeyReq{
+		Value:     tk.ApiKey,
+		TimeAdded: time.Now(),
+		Cost:      cost,
+	})
+}
+
+func (tk *APIKeyMiddleware) Take(w http.ResponseWriter, r *http.Request) (time.Duration, error) {
+	tkDB := tk.Storage.ApiKeyRepository()
+	tkReq := usecase.NewRegisterAPIKeyUseCase(tkDB, tk.Config).WithLimitFactor(tk.Factor)
+	take, takeErr := tkReq.Take(r.Context(), dto.ApiKeyReq{
+		Value:     tk.ApiKey,
+		TimeAdded: time.Now(),
+		Cost:      tk.Cost,
+	})
+	if takeErr != nil {
+		return 0, takeErr
+	}
+	if !take.Allow {
+		return take.RetryAfter, nil
+	}
+
+	setLimitHeaders(w, take.Limit, take.Remaining)
+	trace.SpanFromContext(r.Context()).SetAttributes(tracing.RemainingKey.Int(take.Remaining))
+	return 0, nil
+}
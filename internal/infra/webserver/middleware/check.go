@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/MatheusBenetti/rate-limiter/config"
+)
+
+// Headers the edge proxies use to forward the original request to the check endpoint
+const (
+	originalURIHeader     = "X-Original-URI"
+	originalMethodHeader  = "X-Original-Method"
+	realIPHeader          = "X-Real-IP"
+	envoyExternalIPHeader = "X-Envoy-External-Address"
+	forwardedForHeader    = "X-Forwarded-For"
+)
+
+// Check decides on a request forwarded by an edge proxy without serving it, it answers 200 when the request is
+// allowed and the denied status when it goes over the limit. Behind nginx the original path, method and client IP
+// are taken from the headers set in the auth_request location, behind Envoy from the path after check.Path as
+// ext_authz sends it and from the address Envoy itself appends. The body of the request is never read
+func (m *Middleware) Check(check config.Check) http.Handler {
+	limiter := m.RateLimiter(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	deniedStatus := check.Denied()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		original, err := forwardedRequest(r, check)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		limiter.ServeHTTP(&checkWriter{ResponseWriter: w, deniedStatus: deniedStatus}, original)
+	})
+}
+
+// checkWriter answers the requests over the limit with the denied status the edge proxy expects
+type checkWriter struct {
+	http.ResponseWriter
+	deniedStatus int
+}
+
+func (cw *checkWriter) WriteHeader(status int) {
+	if status == http.StatusTooManyRequests {
+		status = cw.deniedStatus
+	}
+
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func forwardedRequest(r *http.Request, check config.Check) (*http.Request, error) {
+	var uri, method string
+	if check.Nginx() {
+		uri = r.Header.Get(originalURIHeader)
+		method = r.Header.Get(originalMethodHeader)
+	}
+	if uri == "" {
+		uri = "/" + strings.TrimLeft(strings.TrimPrefix(r.URL.RequestURI(), check.Path), "/")
+	}
+	target, err := url.ParseRequestURI(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid forwarded uri %q", uri)
+	}
+
+	original := r.Clone(r.Context())
+	original.Body = http.NoBody
+	original.ContentLength = 0
+	original.URL = target
+	original.RequestURI = uri
+	if method != "" {
+		original.Method = method
+	}
+	if ip := clientIP(r, check); ip != "" {
+		original.RemoteAddr = net.JoinHostPort(ip, "0")
+	}
+
+	return original, nil
+}
+
+// clientIP reads the address of the client from the header the edge proxy overwrites, X-Real-IP behind nginx
+// and X-Envoy-External-Address behind Envoy, a header the other proxy would pass through untouched is ignored.
+// Of X-Forwarded-For only the last entry is used, the one appended by the proxy itself, since the client can
+// forge the others
+func clientIP(r *http.Request, check config.Check) string {
+	header := envoyExternalIPHeader
+	if check.Nginx() {
+		header = realIPHeader
+	}
+
+	ip := r.Header.Get(header)
+	if ip == "" {
+		forwarded := strings.Split(r.Header.Get(forwardedForHeader), ",")
+		ip = strings.TrimSpace(forwarded[len(forwarded)-1])
+	}
+	if net.ParseIP(ip) == nil {
+		return ""
+	}
+
+	return ip
+}
@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MatheusBenetti/rate-limiter/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForwardedRequest(t *testing.T) {
+	envoy := config.Check{Path: "/check"}
+	nginx := config.Check{Path: "/check", Proxy: config.CheckNginx}
+
+	tests := []struct {
+		name           string
+		check          config.Check
+		target         string
+		headers        map[string]string
+		expectedPath   string
+		expectedMethod string
+		expectedAddr   string
+	}{
+		{
+			name:           "nginx original request",
+			check:          nginx,
+			target:         "/check",
+			headers:        map[string]string{"X-Original-URI": "/login?next=/", "X-Original-Method": "POST", "X-Real-IP": "203.0.113.7"},
+			expectedPath:   "/login",
+			expectedMethod: http.MethodPost,
+			expectedAddr:   "203.0.113.7:0",
+		},
+		{
+			name:           "nginx ignores the envoy address",
+			check:          nginx,
+			target:         "/check",
+			headers:        map[string]string{"X-Envoy-External-Address": "203.0.113.9", "X-Forwarded-For": "198.51.100.1, 203.0.113.7"},
+			expectedPath:   "/",
+			expectedMethod: http.MethodGet,
+			expectedAddr:   "203.0.113.7:0",
+		},
+		{
+			name:           "envoy path after the prefix",
+			check:          envoy,
+			target:         "/check/orders/1?page=2",
+			headers:        map[string]string{"X-Envoy-External-Address": "203.0.113.7"},
+			expectedPath:   "/orders/1",
+			expectedMethod: http.MethodGet,
+			expectedAddr:   "203.0.113.7:0",
+		},
+		{
+			name:   "envoy ignores the nginx headers",
+			check:  envoy,
+			target: "/check/orders",
+			headers: map[string]string{
+				"X-Original-URI":    "/public",
+				"X-Original-Method": "DELETE",
+				"X-Real-IP":         "198.51.100.1",
+				"X-Forwarded-For":   "198.51.100.1, 203.0.113.7",
+			},
+			expectedPath:   "/orders",
+			expectedMethod: http.MethodGet,
+			expectedAddr:   "203.0.113.7:0",
+		},
+		{
+			name:           "no forwarded address keeps the peer",
+			check:          envoy,
+			target:         "/check/orders",
+			headers:        map[string]string{"X-Forwarded-For": "not-an-ip"},
+			expectedPath:   "/orders",
+			expectedMethod: http.MethodGet,
+			expectedAddr:   "10.0.0.1:1234",
+		},
+	}
+
+	for i := 0; i < len(tests); i++ {
+		t.Run(tests[i].name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tests[i].target, nil)
+			req.RemoteAddr = "10.0.0.1:1234"
+			for key, value := range tests[i].headers {
+				req.Header.Set(key, value)
+			}
+
+			original, err := forwardedRequest(req, tests[i].check)
+			require.NoError(t, err)
+			assert.Equal(t, tests[i].expectedPath, original.URL.Path)
+			assert.Equal(t, tests[i].expectedMethod, original.Method)
+			assert.Equal(t, tests[i].expectedAddr, original.RemoteAddr)
+			assert.Equal(t, http.NoBody, original.Body)
+		})
+	}
+}
+
+func TestForwardedRequestInvalidURI(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/check", nil)
+	req.Header.Set("X-Original-URI", "login")
+
+	_, err := forwardedRequest(req, config.Check{Path: "/check", Proxy: config.CheckNginx})
+	assert.Error(t, err)
+}
+
+func TestCheckDeniedStatus(t *testing.T) {
+	tests := []struct {
+		name           string
+		check          config.Check
+		expectedDenied int
+	}{
+		{name: "envoy", check: config.Check{Path: "/check"}, expectedDenied: http.StatusTooManyRequests},
+		{name: "nginx", check: config.Check{Path: "/check", Proxy: config.CheckNginx}, expectedDenied: http.StatusForbidden},
+		{
+			name:           "configured",
+			check:          config.Check{Path: "/check", Proxy: config.CheckNginx, DeniedStatus: http.StatusUnauthorized},
+			expectedDenied: http.StatusUnauthorized,
+		},
+	}
+
+	for i := 0; i < len(tests); i++ {
+		t.Run(tests[i].name, func(t *testing.T) {
+			cfg := &config.Config{Check: tests[i].check}
+			cfg.RateLimiter.ByIp = config.LimitValues{MaxReq: 1, TimeWindow: 60, BlockDuration: 60}
+			m, _ := newMiddleware(t, cfg)
+			check := m.Check(tests[i].check)
+
+			headers := map[string]string{"X-Original-URI": "/orders", "X-Real-IP": "203.0.113.7"}
+			assert.Equal(t, http.StatusOK, serve(check, "/check/orders", headers).Code)
+			assert.Equal(t, tests[i].expectedDenied, serve(check, "/check/orders", headers).Code)
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/MatheusBenetti/rate-limiter/config"
+	"github.com/MatheusBenetti/rate-limiter/internal/dto"
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/MatheusBenetti/rate-limiter/internal/usecase"
+)
+
+// ConcurrencyMiddleware caps the simultaneous in-flight requests of an identity using distributed semaphores
+type ConcurrencyMiddleware struct {
+	Storage Storage
+	Config  *config.Config
+}
+
+type semaphoreSlot struct {
+	key   string
+	limit int
+}
+
+func (cm *ConcurrencyMiddleware) slots(r *http.Request, apiKey string, route config.RouteValues, path string) []semaphoreSlot {
+	identity := identityKey(r, apiKey)
+	limit := cm.Config.RateLimiter.Concurrency.ByIp
+	if apiKey != "" {
+		limit = cm.Config.RateLimiter.Concurrency.ByApiKey
+	}
+
+	slots := make([]semaphoreSlot, 0, 2)
+	if limit > 0 {
+		slots = append(slots, semaphoreSlot{
+			key:   fmt.Sprintf("%s:%s", entity.SemaphorePrefixKey, identity),
+			limit: limit,
+		})
+	}
+	if route.MaxInFlight > 0 {
+		slots = append(slots, semaphoreSlot{
+			key:   fmt.Sprintf("%s:%s:%s", entity.SemaphorePrefixKey, path, identity),
+			limit: route.MaxInFlight,
+		})
+	}
+
+	return slots
+}
+
+// Acquire holds a lease on every semaphore the request falls into, the returned func releases them.
+// When a slot is not available the error response is already written, storage errors are left to the fail mode
+func (cm *ConcurrencyMiddleware) Acquire(
+	w http.ResponseWriter,
+	r *http.Request,
+	apiKey string,
+	route config.RouteValues,
+	path string,
+) (func(), error) {
+	slots := cm.slots(r, apiKey, route, path)
+	if len(slots) == 0 {
+		return func() {}, nil
+	}
+
+	semaphoreDB := cm.Storage.SemaphoreRepository()
+	slotReq := usecase.NewAcquireSlotUseCase(semaphoreDB)
+	leaseTTL := time.Duration(cm.Config.RateLimiter.Concurrency.LeaseTTL) * time.Second
+
+	leases := make([]*entity.Lease, 0, len(slots))
+	for _, slot := range slots {
+		lease, execErr := slotReq.Execute(r.Context(), dto.SlotReq{
+			Key:      slot.key,
+			Limit:    slot.limit,
+			LeaseTTL: leaseTTL,
+		})
+		if execErr != nil {
+			releaseLeases(r.Context(), semaphoreDB, leases)
+			if errors.Is(execErr, entity.ErrConcurrencyLimit) {
+				http.Error(w, execErr.Error(), http.StatusTooManyRequests)
+				return nil, execErr
+			}
+
+			slog.ErrorContext(r.Context(), "error executing NewAcquireSlotUseCase", "error", execErr)
+			return nil, undecided(w, execErr)
+		}
+		leases = append(leases, lease)
+	}
+
+	done := make(chan struct{})
+	go keepLeasesAlive(r.Context(), semaphoreDB, leases, done)
+
+	return func() {
+		close(done)
+		releaseLeases(r.Context(), semaphoreDB, leases)
+	}, nil
+}
+
+// keepLeasesAlive refreshes the leases while the request is in flight, so only a dead replica lets them expire
+func keepLeasesAlive(ctx context.Context, repository entity.SemaphoreRepository, leases []*entity.Lease, done <-chan struct{}) {
+	ticker := time.NewTicker(leases[0].RefreshInterval())
+	defer ticker.Stop()
+
+	ctx = context.WithoutCancel(ctx)
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			for _, lease := range leases {
+				refreshed, err := repository.Refresh(ctx, lease)
+				if err != nil {
+					slog.ErrorContext(ctx, "error refreshing in-flight lease", "error", err)
+					continue
+				}
+				if !refreshed {
+					slog.WarnContext(ctx, "in-flight lease expired before the request finished")
+				}
+			}
+		}
+	}
+}
+
+func releaseLeases(ctx context.Context, repository entity.SemaphoreRepository, leases []*entity.Lease) {
+	// the request context may be already cancelled, the slot must be released anyway
+	ctx = context.WithoutCancel(ctx)
+	for _, lease := range leases {
+		if err := repository.Release(ctx, lease); err != nil {
+			slog.ErrorContext(ctx, "error releasing in-flight lease", "error", err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+)
+
+type costCtxKey struct{}
+
+// SetRequestCost lets a handler report the cost of the request it is serving,
+// it returns false when the request is not guarded by the rate limiter
+func SetRequestCost(ctx context.Context, cost int) bool {
+	reported, ok := ctx.Value(costCtxKey{}).(*atomic.Int64)
+	if !ok {
+		return false
+	}
+
+	reported.Store(int64(cost))
+	return true
+}
+
+func withCost(r *http.Request) (*http.Request, *atomic.Int64) {
+	reported := &atomic.Int64{}
+	return r.WithContext(context.WithValue(r.Context(), costCtxKey{}, reported)), reported
+}
+
+// responseWriter keeps the status code written by the handler and strips the cost header from the response
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	cost        int
+}
+
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	return &responseWriter{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (rw *responseWriter) WriteHeader(code int) {
+	if rw.wroteHeader {
+		return
+	}
+
+	rw.wroteHeader = true
+	rw.status = code
+	rw.readCostHeader()
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	return rw.ResponseWriter.Write(b)
+}
+
+// Flush sends the buffered response to the client, streaming handlers and the proxy rely on it
+func (rw *responseWriter) Flush() {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	_ = http.NewResponseController(rw.ResponseWriter).Flush()
+}
+
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+func (rw *responseWriter) readCostHeader() {
+	value := rw.Header().Get(entity.CostHeader)
+	if value == "" {
+		return
+	}
+
+	rw.Header().Del(entity.CostHeader)
+	cost, err := strconv.Atoi(value)
+	if err != nil {
+		return
+	}
+	rw.cost = cost
+}
+
+// reportedCost returns the cost reported by the handler through the context or the response header
+func (rw *responseWriter) reportedCost(fromCtx *atomic.Int64) int {
+	if !rw.wroteHeader {
+		rw.readCostHeader()
+	}
+
+	if ctxCost := int(fromCtx.Load()); ctxCost > rw.cost {
+		return ctxCost
+	}
+
+	return rw.cost
+}
@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseWriterFlush(t *testing.T) {
+	rec := httptest.NewRecorder()
+	var w http.ResponseWriter = newResponseWriter(rec)
+
+	flusher, ok := w.(http.Flusher)
+	require.True(t, ok, "the wrapped writer should keep http.Flusher")
+
+	flusher.Flush()
+	require.True(t, rec.Flushed)
+	require.Equal(t, http.StatusOK, rec.Code)
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/MatheusBenetti/rate-limiter/config"
+	"github.com/MatheusBenetti/rate-limiter/internal/dto"
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/MatheusBenetti/rate-limiter/internal/usecase"
+)
+
+// fallThrough applies the fail mode, failing open lets the request go on and failing closed rejects it
+func fallThrough(w http.ResponseWriter, failOpen bool) bool {
+	if failOpen {
+		return true
+	}
+
+	http.Error(w, entity.ErrBackendUnavailable.Error(), http.StatusServiceUnavailable)
+	return false
+}
+
+// unavailable answers a request the limiter could not decide on, it returns true when the request may go on.
+// With the breaker fallback enabled the request is limited in memory until redis recovers
+func (m *Middleware) unavailable(w http.ResponseWriter, r *http.Request, cfg *config.Config, key string, cost int, failOpen bool, err error) bool {
+	if !isUndecided(err) {
+		return false
+	}
+
+	if !cfg.RateLimiter.Breaker.Fallback {
+		return fallThrough(w, failOpen)
+	}
+
+	fallbackReq := usecase.NewRegisterIPPolicyUseCase(m.fallback, cfg.RateLimiter.ByIp)
+	execute, execErr := fallbackReq.Execute(r.Context(), dto.IpReq{
+		IP:        key,
+		TimeAdded: time.Now(),
+		Cost:      cost,
+	})
+	if errors.Is(execErr, entity.ErrIpAmountReq) || (execErr == nil && !execute.Allow) {
+		http.Error(w, entity.ErrIpAmountReq.Error(), http.StatusTooManyRequests)
+		return false
+	}
+	if execErr != nil {
+		slog.ErrorContext(r.Context(), "error executing fallback limiter", "error", execErr)
+		return fallThrough(w, failOpen)
+	}
+
+	return true
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/MatheusBenetti/rate-limiter/config"
+	"github.com/MatheusBenetti/rate-limiter/internal/dto"
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/MatheusBenetti/rate-limiter/internal/usecase"
+)
+
+const failuresNamespace = "failures"
+
+// FailureMiddleware rejects the identities blocked for too many failures and counts the responses afterwards
+// when their status is a failure, on top of the other limits of the route
+type FailureMiddleware struct {
+	Storage Storage
+	Values  config.FailureValues
+	Path    string
+}
+
+func (fm *FailureMiddleware) identity(r *http.Request) string {
+	if fm.Values.IdentityHeader != "" {
+		if value := r.Header.Get(fm.Values.IdentityHeader); value != "" {
+			return fmt.Sprintf("user:%s", value)
+		}
+	}
+
+	return getIP(r.RemoteAddr)
+}
+
+// strategy names the kind of identity the failures are counted by
+func (fm *FailureMiddleware) strategy(r *http.Request) string {
+	if fm.Values.IdentityHeader != "" && r.Header.Get(fm.Values.IdentityHeader) != "" {
+		return "header"
+	}
+
+	return "ip"
+}
+
+// Check rejects the identities blocked for too many failures, the returned error tells whether the identity
+// was blocked or the limiter could not check it. No response is written when it could not check it
+func (fm *FailureMiddleware) Check(w http.ResponseWriter, r *http.Request) error {
+	blockedErr := fm.useCase().CheckBlocked(r.Context(), fm.identity(r))
+	if errors.Is(blockedErr, entity.ErrTooManyFailures) {
+		http.Error(w, blockedErr.Error(), http.StatusTooManyRequests)
+		return blockedErr
+	}
+	if blockedErr != nil {
+		slog.ErrorContext(r.Context(), "error executing NewRegisterFailureUseCase", "error", blockedErr)
+		return undecided(w, blockedErr)
+	}
+
+	return nil
+}
+
+// Record counts the response of a served request when its status is a failure
+func (fm *FailureMiddleware) Record(r *http.Request, status int) {
+	if execErr := fm.useCase().Execute(r.Context(), dto.FailureReq{
+		Identity:  fm.identity(r),
+		TimeAdded: time.Now(),
+		Status:    status,
+	}); execErr != nil {
+		slog.ErrorContext(r.Context(), "error recording request outcome", "error", execErr)
+	}
+}
+
+func (fm *FailureMiddleware) useCase() *usecase.RegisterFailure {
+	failureDB := fm.Storage.IPRepository(fmt.Sprintf("%s%s", failuresNamespace, fm.Path))
+	return usecase.NewRegisterFailureUseCase(failureDB, fm.Values)
+}
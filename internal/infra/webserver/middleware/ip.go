@@ -2,7 +2,7 @@ package middleware
 
 import (
 	"errors"
-	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"time"
@@ -10,14 +10,21 @@ import (
 	"github.com/MatheusBenetti/rate-limiter/config"
 	"github.com/MatheusBenetti/rate-limiter/internal/dto"
 	"github.com/MatheusBenetti/rate-limiter/internal/entity"
-	"github.com/MatheusBenetti/rate-limiter/internal/infra/database"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/metrics"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/tracing"
 	"github.com/MatheusBenetti/rate-limiter/internal/usecase"
-	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type IPMiddleware struct {
-	RedisClient *redis.Client
-	Config      *config.Config
+	Storage Storage
+	Metrics *metrics.Metrics
+	Config  *config.Config
+	Cost    int
+	Shadow  bool
+	Route   string
+	// Factor scales the by_ip limit, set by the adaptive limits
+	Factor float64
 }
 
 func getIP(remoteAddr string) string {
@@ -29,29 +36,88 @@ func getIP(remoteAddr string) string {
 	return ip
 }
 
+// shadow evaluates the by_ip policy without enforcing it
+func (ip *IPMiddleware) shadow(r *http.Request, cost int) {
+	shadow := &ShadowMiddleware{
+		Storage:    ip.Storage,
+		Metrics:    ip.Metrics,
+		Policy:     ip.Config.RateLimiter.ByIp,
+		PolicyName: "by_ip",
+		Namespace:  shadowNamespace,
+		Route:      ip.Route,
+	}
+	shadow.Evaluate(r, getIP(r.RemoteAddr), cost)
+}
+
 func (ip *IPMiddleware) Execute(w http.ResponseWriter, r *http.Request) error {
-	ipDB := database.NewIPRedis(ip.RedisClient)
-	ipReq := usecase.NewRegisterIPUseCase(ipDB, ip.Config)
+	if ip.Shadow {
+		ip.shadow(r, ip.Cost)
+		return nil
+	}
+
+	ipDB := ip.Storage.IPRepository("")
+	ipReq := usecase.NewRegisterIPUseCase(ipDB, ip.Config).WithLimitFactor(ip.Factor)
 	execute, execErr := ipReq.Execute(r.Context(), dto.IpReq{
 		IP:        getIP(r.RemoteAddr),
 		TimeAdded: time.Now(),
+		Cost:      ip.Cost,
 	})
 	if errors.Is(execErr, entity.ErrIpAmountReq) {
-		log.Printf("Error executing NewRegisterIPUseCase: %s\n", execErr.Error())
+		w.Header().Set(entity.RemainingHeader, "0")
 		http.Error(w, execErr.Error(), http.StatusTooManyRequests)
 		return execErr
 	}
 	if execErr != nil {
-		log.Printf("Error executing NewRegisterIPUseCase: %s\n", execErr.Error())
-		http.Error(w, execErr.Error(), http.StatusInternalServerError)
-		return execErr
+		slog.ErrorContext(r.Context(), "error executing NewRegisterIPUseCase", "error", execErr)
+		return undecided(w, execErr)
 	}
 
+	setLimitHeaders(w, execute.Limit, execute.Remaining)
 	if !execute.Allow {
-		log.Printf("Too many request: %s\n", entity.ErrIpAmountReq.Error())
 		http.Error(w, entity.ErrIpAmountReq.Error(), http.StatusTooManyRequests)
-		return errors.New("too many request")
+		return errTooManyRequests
 	}
 
+	trace.SpanFromContext(r.Context()).SetAttributes(tracing.RemainingKey.Int(execute.Remaining))
 	return nil
 }
+
+func (ip *IPMiddleware) Charge(r *http.Request, cost int) error {
+	if ip.Shadow {
+		ip.shadow(r, cost)
+		return nil
+	}
+
+	ipDB := ip.Storage.IPRepository("")
+	ipReq := usecase.NewRegisterIPUseCase(ipDB, ip.Config).WithLimitFactor(ip.Factor)
+	return ipReq.Charge(r.Context(), dto.IpReq{
+		IP:        getIP(r.RemoteAddr),
+		TimeAdded: time.Now(),
+		Cost:      cost,
+	})
+}
+
+func (ip *IPMiddleware) Take(w http.ResponseWriter, r *http.Request) (time.Duration, error) {
+	if ip.Shadow {
+		ip.shadow(r, ip.Cost)
+		return 0, nil
+	}
+
+	ipDB := ip.Storage.IPRepository("")
+	ipReq := usecase.NewRegisterIPUseCase(ipDB, ip.Config).WithLimitFactor(ip.Factor)
+	take, takeErr := ipReq.Take(r.Context(), dto.IpReq{
+		IP:        getIP(r.RemoteAddr),
+		TimeAdded: time.Now(),
+		Cost:      ip.Cost,
+	})
+	if takeErr != nil {
+		return 0, takeErr
+	}
+	if !take.Allow {
+		return take.RetryAfter, nil
+	}
+
+	setLimitHeaders(w, take.Limit, take.Remaining)
+	trace.SpanFromContext(r.Context()).SetAttributes(tracing.RemainingKey.Int(take.Remaining))
+	return 0, nil
+}
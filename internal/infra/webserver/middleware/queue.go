@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/MatheusBenetti/rate-limiter/config"
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+)
+
+const defaultMaxQueued = 100
+
+type queueEntry struct {
+	turn    chan struct{}
+	waiting int
+}
+
+// Queue holds the requests over the limit until a slot frees up, leaky bucket style.
+// Requests of the same key take turns so only one of them claims the next slot. The queue lives in the
+// memory of the replica, requests waiting on other replicas may still claim the slot first
+type Queue struct {
+	mu      sync.Mutex
+	entries map[string]*queueEntry
+}
+
+func NewQueue() *Queue {
+	return &Queue{entries: make(map[string]*queueEntry)}
+}
+
+func (q *Queue) join(key string, maxQueued int) (*queueEntry, bool) {
+	if maxQueued <= 0 {
+		maxQueued = defaultMaxQueued
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entry, ok := q.entries[key]
+	if !ok {
+		entry = &queueEntry{turn: make(chan struct{}, 1)}
+		q.entries[key] = entry
+	}
+
+	if entry.waiting >= maxQueued {
+		return nil, false
+	}
+
+	entry.waiting++
+	return entry, true
+}
+
+func (q *Queue) leave(key string, entry *queueEntry) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entry.waiting--
+	if entry.waiting == 0 {
+		delete(q.entries, key)
+	}
+}
+
+// Delay waits up to the configured delay for the strategy to take a free slot, each attempt takes the slot
+// only when it fits so a request that loses it to another replica waits again instead of blocking the identity.
+// The error response is already written when it returns an error the limiter decided on, and a request
+// canceled while waiting returns the error of its context
+func (q *Queue) Delay(
+	w http.ResponseWriter,
+	r *http.Request,
+	strategy StrategyMiddleware,
+	key string,
+	values config.DelayValues,
+) error {
+	entry, joined := q.join(key, values.MaxQueued)
+	if !joined {
+		http.Error(w, entity.ErrQueueFull.Error(), http.StatusTooManyRequests)
+		return entity.ErrQueueFull
+	}
+	defer q.leave(key, entry)
+
+	maxDelay := time.Duration(values.MaxDelay) * time.Millisecond
+	deadline := time.Now().Add(maxDelay)
+	turnTimer := time.NewTimer(maxDelay)
+	defer turnTimer.Stop()
+
+	select {
+	case entry.turn <- struct{}{}:
+		defer func() { <-entry.turn }()
+	case <-turnTimer.C:
+		http.Error(w, entity.ErrDelayExceeded.Error(), http.StatusTooManyRequests)
+		return entity.ErrDelayExceeded
+	case <-r.Context().Done():
+		return r.Context().Err()
+	}
+
+	for {
+		wait, takeErr := strategy.Take(w, r)
+		if errors.Is(takeErr, entity.ErrIpAmountReq) || errors.Is(takeErr, entity.ErrApiKeyAmountReq) {
+			w.Header().Set(entity.RemainingHeader, "0")
+			http.Error(w, takeErr.Error(), http.StatusTooManyRequests)
+			return takeErr
+		}
+		if errors.Is(takeErr, entity.ErrUnknownApiKey) {
+			http.Error(w, takeErr.Error(), http.StatusUnauthorized)
+			return takeErr
+		}
+		if takeErr != nil {
+			slog.ErrorContext(r.Context(), "error taking the next available slot", "error", takeErr)
+			return undecided(w, takeErr)
+		}
+
+		if wait <= 0 {
+			return nil
+		}
+
+		if time.Now().Add(wait).After(deadline) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, entity.ErrDelayExceeded.Error(), http.StatusTooManyRequests)
+			return entity.ErrDelayExceeded
+		}
+
+		waitTimer := time.NewTimer(wait)
+		select {
+		case <-waitTimer.C:
+		case <-r.Context().Done():
+			waitTimer.Stop()
+			return r.Context().Err()
+		}
+	}
+}
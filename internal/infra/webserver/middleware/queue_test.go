@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MatheusBenetti/rate-limiter/config"
+	"github.com/MatheusBenetti/rate-limiter/internal/dto"
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/metrics"
+	"github.com/MatheusBenetti/rate-limiter/internal/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func delayedConfig() *config.Config {
+	cfg := &config.Config{}
+	cfg.RateLimiter.ByIp = config.LimitValues{MaxReq: 3, TimeWindow: 1, BlockDuration: 60}
+	cfg.RateLimiter.Routes = map[string]config.RouteValues{
+		"/slow": {Delay: config.DelayValues{MaxDelay: 5000}},
+	}
+	return cfg
+}
+
+// racingStorage lets a request of another replica take the last slot right after the limiter first
+// reads the requests of the identity, before it records its own
+type racingStorage struct {
+	*Middleware
+	race func(repository entity.IPRepository)
+}
+
+func (s *racingStorage) IPRepository(namespace string) entity.IPRepository {
+	return &racingRepository{IPRepository: s.Middleware.IPRepository(namespace), storage: s}
+}
+
+type racingRepository struct {
+	entity.IPRepository
+	storage *racingStorage
+}
+
+func (r *racingRepository) runRace() {
+	if race := r.storage.race; race != nil {
+		r.storage.race = nil
+		race(r.IPRepository)
+	}
+}
+
+func (r *racingRepository) GetRequest(ctx context.Context, key string) (*entity.RateLimiter, error) {
+	rl, err := r.IPRepository.GetRequest(ctx, key)
+	r.runRace()
+	return rl, err
+}
+
+func (r *racingRepository) UpdateRequest(ctx context.Context, key string, update func(rl *entity.RateLimiter) bool) error {
+	return r.IPRepository.UpdateRequest(ctx, key, func(rl *entity.RateLimiter) bool {
+		r.runRace()
+		return update(rl)
+	})
+}
+
+func TestDelayedRequestLosingTheSlotDoesNotBlock(t *testing.T) {
+	cfg := delayedConfig()
+	m, _ := newMiddleware(t, cfg)
+	storage := &racingStorage{Middleware: m, race: func(repository entity.IPRepository) {
+		rival, err := usecase.NewRegisterIPUseCase(repository, cfg).Execute(context.Background(), dto.IpReq{
+			IP:        "10.0.0.1",
+			TimeAdded: time.Now(),
+		})
+		require.NoError(t, err)
+		require.True(t, rival.Allow)
+	}}
+	strategy := &IPMiddleware{Storage: storage, Config: cfg, Cost: 1}
+
+	req := httptest.NewRequest(http.MethodGet, "/slow", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	ipReq := usecase.NewRegisterIPUseCase(m.IPRepository(""), cfg)
+	for i := 0; i < cfg.RateLimiter.ByIp.MaxReq-1; i++ {
+		_, err := ipReq.Execute(context.Background(), dto.IpReq{IP: "10.0.0.1", TimeAdded: time.Now()})
+		require.NoError(t, err)
+	}
+
+	rec := httptest.NewRecorder()
+	require.NoError(t, NewQueue().Delay(rec, req, strategy, "ip_10.0.0.1", cfg.RateLimiter.Route("/slow").Delay),
+		"the request waits for the next slot")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	status, err := m.IPRepository("").GetBlockedDuration(context.Background(), "10.0.0.1")
+	require.NoError(t, err)
+	assert.Empty(t, status, "losing the slot to another request never blocks the identity")
+}
+
+func TestDelayCanceledWhileWaiting(t *testing.T) {
+	cfg := delayedConfig()
+	m, _ := newMiddleware(t, cfg)
+	strategy := Factory("", 1, "/slow", cfg, m)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "/slow", nil).WithContext(ctx)
+	req.RemoteAddr = "10.0.0.1:1234"
+	for i := 0; i < cfg.RateLimiter.ByIp.MaxReq; i++ {
+		wait, err := strategy.Take(httptest.NewRecorder(), req)
+		require.NoError(t, err)
+		require.Zero(t, wait)
+	}
+
+	err := NewQueue().Delay(httptest.NewRecorder(), req, strategy, "ip_10.0.0.1", cfg.RateLimiter.Route("/slow").Delay)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, metrics.DecisionCanceled, decisionOf(err))
+}
@@ -1,28 +1,218 @@
 package middleware
 
 import (
+	"context"
+	"errors"
+	"log/slog"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/MatheusBenetti/rate-limiter/config"
 	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/database"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/logger"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/metrics"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/tracing"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Middleware struct {
-	RedisClient *redis.Client
-	Config      *config.Config
+	RedisClient redis.UniversalClient
+	Config      *config.Store
+	Metrics     *metrics.Metrics
+	Adaptive    *Adaptive
+	CIDRBlocks  *database.CIDRBlocks
+	queue       *Queue
+	shedder     *Shedder
+	fallback    *database.IPMemory
 }
 
 func (m *Middleware) RateLimiter(next http.Handler) http.Handler {
+	if m.queue == nil {
+		m.queue = NewQueue()
+	}
+	if m.fallback == nil {
+		m.fallback = database.NewIPMemory()
+	}
+	if m.shedder == nil {
+		m.shedder = NewShedder()
+	}
+
 	return http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
+			// the whole request is decided with the configuration in use when it arrived
+			cfg := m.Config.Current()
+			r = tracing.Extract(r)
+			path := strings.ToLower(r.URL.Path)
+			route := cfg.RateLimiter.Route(path)
+			routeLabel := m.routeLabel(cfg, path)
+			failOpen := cfg.RateLimiter.FailsOpen(route)
+			cost := route.Cost
+			if cost == 0 {
+				cost = 1
+			}
+
 			apiKey := r.Header.Get(entity.ApiKeyHeader)
-			strategy := Factory(apiKey, m)
-			if err := strategy.Execute(w, r); err != nil {
+			strategyLabel, identity := strategyName(apiKey), identityKey(r, apiKey)
+			var failures *FailureMiddleware
+			if route.Failures.Enabled() {
+				failures = &FailureMiddleware{
+					Storage: m,
+					Values:  route.Failures,
+					Path:    path,
+				}
+			}
+			r = r.WithContext(logger.WithAttrs(r.Context(),
+				slog.String(logger.StrategyKey, strategyLabel),
+				slog.String(logger.IdentityHashKey, logger.HashIdentity(identity)),
+			))
+
+			// the decision span ends before the request is served, its steps run with decisionReq
+			ctx, span := tracing.Tracer().Start(r.Context(), "rate_limiter.decision",
+				trace.WithAttributes(tracing.RouteKey.String(routeLabel), tracing.CostKey.Int(cost)),
+			)
+			decisionReq := r.WithContext(ctx)
+
+			if shedding := cfg.RateLimiter.Shedding; shedding.Enabled() {
+				class := m.priorityClass(decisionReq, cfg, route, apiKey)
+				release, err := m.shedder.Acquire(w, shedding, class)
+				if err != nil {
+					m.record(decisionReq, strategyLabel, sheddingPolicy, routeLabel, err)
+					span.End()
+					return
+				}
+				defer release()
+			}
+
+			if failures != nil {
+				err := failures.Check(w, decisionReq)
+				m.record(decisionReq, failures.strategy(r), failuresNamespace, routeLabel, err)
+				if err != nil && (!isUndecided(err) || !fallThrough(w, failOpen)) {
+					span.End()
+					return
+				}
+			}
+
+			if route.Shadow != nil {
+				shadow := &ShadowMiddleware{
+					Storage:    m,
+					Metrics:    m.Metrics,
+					Policy:     *route.Shadow,
+					PolicyName: routeLabel,
+					Namespace:  shadowNamespace + path,
+					Route:      routeLabel,
+				}
+				shadow.Evaluate(decisionReq, identity, cost)
+			}
+
+			// the slot is taken before the window is charged, so a request rejected for concurrency
+			// never consumes the budget of the identity
+			concurrency := &ConcurrencyMiddleware{Storage: m, Config: cfg}
+			release, err := concurrency.Acquire(w, decisionReq, apiKey, route, path)
+			if err != nil {
+				m.record(decisionReq, strategyLabel, "concurrency", routeLabel, err)
+				if !isUndecided(err) || !fallThrough(w, failOpen) {
+					span.End()
+					return
+				}
+				release = func() {}
+			}
+			defer release()
+
+			strategy := Factory(apiKey, cost, routeLabel, cfg, m)
+			if route.Delay.Enabled() {
+				err = m.queue.Delay(w, decisionReq, strategy, identity, route.Delay)
+			} else {
+				err = strategy.Execute(w, decisionReq)
+			}
+			m.record(decisionReq, strategyLabel, policyName(apiKey), routeLabel, err)
+			if err != nil && !m.unavailable(w, decisionReq, cfg, identity, cost, failOpen, err) {
+				span.End()
 				return
 			}
 
-			next.ServeHTTP(w, r)
+			span.End()
+
+			r, reported := withCost(r)
+			rw := newResponseWriter(w)
+			start := time.Now()
+			next.ServeHTTP(rw, r)
+			if failures != nil {
+				failures.Record(r, rw.status)
+			}
+			m.Adaptive.Observe(time.Since(start), rw.status)
+
+			if extra := rw.reportedCost(reported) - cost; extra > 0 {
+				if err := strategy.Charge(r, extra); err != nil {
+					slog.ErrorContext(r.Context(), "error charging reported request cost", "error", err)
+				}
+			}
 		},
 	)
 }
+
+// record reports the outcome of a limiter step to the metrics, to the decision span and to the logs.
+// Allowed requests are only logged at debug level, the rejected ones are sampled by the logger
+func (m *Middleware) record(r *http.Request, strategy, policy, route string, err error) {
+	decision := decisionOf(err)
+	m.Metrics.Decision(strategy, policy, route, decision)
+	span := trace.SpanFromContext(r.Context())
+	span.SetAttributes(
+		tracing.StrategyKey.String(strategy),
+		tracing.PolicyKey.String(policy),
+		tracing.DecisionKey.String(decision),
+		tracing.AllowedKey.Bool(decision == metrics.DecisionAllowed),
+	)
+
+	switch decision {
+	case metrics.DecisionAllowed:
+		slog.DebugContext(r.Context(), "request allowed", logger.PolicyKey, policy, logger.DecisionKey, decision)
+	case metrics.DecisionCanceled:
+		slog.DebugContext(r.Context(), "request canceled before a decision", logger.PolicyKey, policy, logger.DecisionKey, decision)
+	case metrics.DecisionUnavailable:
+		span.RecordError(err)
+		slog.WarnContext(r.Context(), "rate limiter could not decide on the request",
+			logger.PolicyKey, policy, logger.DecisionKey, decision, "error", err)
+	default:
+		slog.InfoContext(r.Context(), "too many requests", logger.PolicyKey, policy, logger.DecisionKey, decision)
+	}
+}
+
+// routeLabel names the route of the path for the metrics, paths without a route policy share one label
+func (m *Middleware) routeLabel(cfg *config.Config, path string) string {
+	if _, ok := cfg.RateLimiter.Routes[path]; ok {
+		return path
+	}
+
+	return metrics.OtherRoute
+}
+
+func policyName(apiKey string) string {
+	if apiKey != "" {
+		return "api_key"
+	}
+
+	return "by_ip"
+}
+
+// decisionOf classifies the outcome of a limiter step for the metrics
+func decisionOf(err error) string {
+	switch {
+	case err == nil:
+		return metrics.DecisionAllowed
+	case isUndecided(err):
+		return metrics.DecisionUnavailable
+	case errors.Is(err, entity.ErrLoadShed):
+		return metrics.DecisionShed
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return metrics.DecisionCanceled
+	case errors.Is(err, entity.ErrIpAmountReq),
+		errors.Is(err, entity.ErrApiKeyAmountReq),
+		errors.Is(err, entity.ErrTooManyFailures):
+		return metrics.DecisionBlocked
+	default:
+		return metrics.DecisionLimited
+	}
+}
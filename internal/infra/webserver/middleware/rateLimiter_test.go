@@ -0,0 +1,160 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MatheusBenetti/rate-limiter/config"
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestMiddleware guards a handler answering status with the rate limiter backed by an in-memory redis
+func newTestMiddleware(t *testing.T, cfg *config.Config, status int) (http.Handler, *miniredis.Miniredis) {
+	t.Helper()
+
+	return guard(t, cfg, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(status)
+	}))
+}
+
+func guard(t *testing.T, cfg *config.Config, next http.Handler) (http.Handler, *miniredis.Miniredis) {
+	t.Helper()
+
+	m, server := newMiddleware(t, cfg)
+	return m.RateLimiter(next), server
+}
+
+func newMiddleware(t *testing.T, cfg *config.Config) (*Middleware, *miniredis.Miniredis) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	return &Middleware{
+		RedisClient: redis.NewClient(&redis.Options{Addr: server.Addr()}),
+		Config:      config.NewStore(cfg),
+	}, server
+}
+
+func serve(handler http.Handler, path string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestFailuresRouteKeepsTheWindowLimit(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.RateLimiter.ByIp = config.LimitValues{MaxReq: 2, TimeWindow: 60, BlockDuration: 60}
+	cfg.RateLimiter.Routes = map[string]config.RouteValues{
+		"/login": {Failures: config.FailureValues{
+			Status:        []int{http.StatusUnauthorized},
+			MaxFailures:   10,
+			TimeWindow:    60,
+			BlockDuration: 60,
+		}},
+	}
+	handler, _ := newTestMiddleware(t, cfg, http.StatusOK)
+
+	require.Equal(t, http.StatusOK, serve(handler, "/login", nil).Code)
+	require.Equal(t, http.StatusOK, serve(handler, "/login", nil).Code)
+	require.Equal(t, http.StatusTooManyRequests, serve(handler, "/login", nil).Code,
+		"a route counting failures should still be limited by the window")
+}
+
+func TestFailuresRouteBlocksAfterTooManyFailures(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.RateLimiter.ByIp = config.LimitValues{MaxReq: 100, TimeWindow: 60, BlockDuration: 60}
+	cfg.RateLimiter.Routes = map[string]config.RouteValues{
+		"/login": {Failures: config.FailureValues{
+			Status:        []int{http.StatusUnauthorized},
+			MaxFailures:   2,
+			TimeWindow:    60,
+			BlockDuration: 60,
+		}},
+	}
+	handler, _ := newTestMiddleware(t, cfg, http.StatusUnauthorized)
+
+	require.Equal(t, http.StatusUnauthorized, serve(handler, "/login", nil).Code)
+	require.Equal(t, http.StatusUnauthorized, serve(handler, "/login", nil).Code)
+	require.Equal(t, http.StatusTooManyRequests, serve(handler, "/login", nil).Code)
+}
+
+func TestConcurrencyRejectionKeepsTheWindowBudget(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.RateLimiter.ByIp = config.LimitValues{MaxReq: 2, TimeWindow: 60, BlockDuration: 60}
+	cfg.RateLimiter.Concurrency = config.ConcurrencyValues{ByIp: 1, LeaseTTL: 10}
+
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	handler, _ := guard(t, cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(started)
+			<-finish
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	done := make(chan int)
+	go func() { done <- serve(handler, "/slow", nil).Code }()
+	<-started
+
+	require.Equal(t, http.StatusTooManyRequests, serve(handler, "/", nil).Code)
+	close(finish)
+	require.Equal(t, http.StatusOK, <-done)
+
+	require.Equal(t, http.StatusOK, serve(handler, "/", nil).Code,
+		"a request rejected for concurrency should not consume the window")
+}
+
+func TestUnknownApiKeyIsRejected(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.RateLimiter.ByIp = config.LimitValues{MaxReq: 100, TimeWindow: 60, BlockDuration: 60}
+	cfg.RateLimiter.FailMode = config.FailOpen
+	handler, _ := newTestMiddleware(t, cfg, http.StatusOK)
+
+	for _, key := range []string{"rotated-1", "rotated-2"} {
+		rec := serve(handler, "/", map[string]string{entity.ApiKeyHeader: key})
+		require.Equal(t, http.StatusUnauthorized, rec.Code,
+			"an unknown key should not fall through the fail mode")
+	}
+}
+
+func TestFailOpenOnlyWhenRedisIsUnreachable(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.RateLimiter.ByIp = config.LimitValues{MaxReq: 1, TimeWindow: 60, BlockDuration: 60}
+	cfg.RateLimiter.FailMode = config.FailOpen
+
+	t.Run("limiter keys are not api keys", func(t *testing.T) {
+		handler, _ := newTestMiddleware(t, cfg, http.StatusOK)
+		require.Equal(t, http.StatusOK, serve(handler, "/", nil).Code)
+		require.Equal(t, http.StatusTooManyRequests, serve(handler, "/", nil).Code)
+
+		for _, key := range []string{"rate:ip_{10.0.0.1}", "block:ip_{10.0.0.1}"} {
+			rec := serve(handler, "/", map[string]string{entity.ApiKeyHeader: key})
+			require.Equal(t, http.StatusUnauthorized, rec.Code, "a blocked client can't send a limiter key to fail open")
+		}
+	})
+
+	t.Run("malformed record", func(t *testing.T) {
+		handler, redisServer := newTestMiddleware(t, cfg, http.StatusOK)
+		require.NoError(t, redisServer.Set(entity.IPPrefixRateKey+"_{10.0.0.1}", "not json"))
+
+		require.Equal(t, http.StatusInternalServerError, serve(handler, "/", nil).Code,
+			"an answer redis did give never falls through the fail mode")
+	})
+
+	t.Run("unreachable redis", func(t *testing.T) {
+		handler, redisServer := newTestMiddleware(t, cfg, http.StatusOK)
+		redisServer.Close()
+
+		require.Equal(t, http.StatusOK, serve(handler, "/", nil).Code)
+	})
+}
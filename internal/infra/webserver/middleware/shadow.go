@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/MatheusBenetti/rate-limiter/config"
+	"github.com/MatheusBenetti/rate-limiter/internal/dto"
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/logger"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/metrics"
+	"github.com/MatheusBenetti/rate-limiter/internal/usecase"
+)
+
+const shadowNamespace = "shadow"
+
+// ShadowMiddleware evaluates a policy with its own counters and never enforces it,
+// the requests it would have blocked are only reported
+type ShadowMiddleware struct {
+	Storage    Storage
+	Metrics    *metrics.Metrics
+	Policy     config.LimitValues
+	PolicyName string
+	Namespace  string
+	Route      string
+}
+
+// Evaluate records the request against the shadow policy and reports whether it would have been blocked
+func (sm *ShadowMiddleware) Evaluate(r *http.Request, key string, cost int) bool {
+	shadowDB := sm.Storage.IPRepository(sm.Namespace)
+	shadowReq := usecase.NewRegisterIPPolicyUseCase(shadowDB, sm.Policy)
+	execute, execErr := shadowReq.Execute(r.Context(), dto.IpReq{
+		IP:        key,
+		TimeAdded: time.Now(),
+		Cost:      cost,
+	})
+	if errors.Is(execErr, entity.ErrIpAmountReq) || (execErr == nil && !execute.Allow) {
+		slog.InfoContext(r.Context(), "shadow policy would have blocked the request",
+			logger.PolicyKey, sm.PolicyName, "method", r.Method, "path", r.URL.Path)
+		sm.Metrics.Decision(shadowNamespace, sm.PolicyName, sm.Route, metrics.DecisionLimited)
+		return true
+	}
+	if execErr != nil {
+		slog.ErrorContext(r.Context(), "error evaluating shadow policy", logger.PolicyKey, sm.PolicyName, "error", execErr)
+		sm.Metrics.Decision(shadowNamespace, sm.PolicyName, sm.Route, metrics.DecisionUnavailable)
+		return false
+	}
+
+	sm.Metrics.Decision(shadowNamespace, sm.PolicyName, sm.Route, metrics.DecisionAllowed)
+	return false
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/MatheusBenetti/rate-limiter/config"
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+)
+
+// sheddingPolicy labels the requests shed by the Shedder in the metrics
+const sheddingPolicy = "shedding"
+
+// Shedder counts the limited requests in flight in the replica and sheds the ones of a priority
+// class that already filled its share of the capacity
+type Shedder struct {
+	inFlight atomic.Int64
+}
+
+func NewShedder() *Shedder {
+	return &Shedder{}
+}
+
+// Acquire takes a slot for a request of the class, the release func must be called once it is served.
+// Requests over the share of their class are answered with 503
+func (s *Shedder) Acquire(w http.ResponseWriter, values config.SheddingValues, class string) (func(), error) {
+	if !values.Enabled() {
+		return func() {}, nil
+	}
+
+	limit := int64(math.Ceil(values.Share(class) * float64(values.Capacity)))
+	for {
+		inFlight := s.inFlight.Load()
+		if inFlight >= limit {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, entity.ErrLoadShed.Error(), http.StatusServiceUnavailable)
+			return nil, entity.ErrLoadShed
+		}
+		if s.inFlight.CompareAndSwap(inFlight, inFlight+1) {
+			return func() { s.inFlight.Add(-1) }, nil
+		}
+	}
+}
+
+// priorityClass picks the shedding class of the request: the route, the plan of a registered API key and
+// last the default class of requests with a registered key or without one. The header comes from the client,
+// so it may only move the request to a class with a lower share
+func (m *Middleware) priorityClass(r *http.Request, cfg *config.Config, route config.RouteValues, apiKey string) string {
+	values := cfg.RateLimiter.Shedding
+	class := m.assignedClass(r, cfg, route, apiKey)
+	if values.Header != "" {
+		if requested := strings.ToLower(r.Header.Get(values.Header)); values.Known(requested) && values.Share(requested) < values.Share(class) {
+			return requested
+		}
+	}
+
+	return class
+}
+
+func (m *Middleware) assignedClass(r *http.Request, cfg *config.Config, route config.RouteValues, apiKey string) string {
+	values := cfg.RateLimiter.Shedding
+	if route.Priority != "" {
+		return route.Priority
+	}
+
+	if apiKey == "" {
+		return values.AnonymousClass
+	}
+
+	// an unknown key, or one that can't be read, is no better than no key at all
+	key, err := m.ApiKeyRepository().Get(r.Context(), apiKey)
+	if err != nil {
+		return values.AnonymousClass
+	}
+	if plan, ok := cfg.RateLimiter.Plans[key.Plan]; ok && plan.Priority != "" {
+		return plan.Priority
+	}
+
+	return values.ApiKeyClass
+}
@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MatheusBenetti/rate-limiter/config"
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sheddingValues() config.SheddingValues {
+	return config.SheddingValues{
+		Capacity:       10,
+		Classes:        map[string]float64{"anonymous": 0.5, "free": 0.8, "paid": 1},
+		Header:         "X-Priority",
+		AnonymousClass: "anonymous",
+		ApiKeyClass:    "free",
+	}
+}
+
+func TestShedderAcquire(t *testing.T) {
+	tests := []struct {
+		name             string
+		values           config.SheddingValues
+		class            string
+		expectedAcquired int
+	}{
+		{name: "anonymous share", values: sheddingValues(), class: "anonymous", expectedAcquired: 5},
+		{name: "free share", values: sheddingValues(), class: "free", expectedAcquired: 8},
+		{name: "whole capacity", values: sheddingValues(), class: "paid", expectedAcquired: 10},
+		{name: "classes are matched lowercased", values: sheddingValues(), class: "PAID", expectedAcquired: 10},
+		{name: "unknown class gets the lowest share", values: sheddingValues(), class: "vip", expectedAcquired: 5},
+	}
+
+	for i := 0; i < len(tests); i++ {
+		t.Run(tests[i].name, func(t *testing.T) {
+			shedder := NewShedder()
+			for acquired := 0; acquired < tests[i].expectedAcquired; acquired++ {
+				_, err := shedder.Acquire(httptest.NewRecorder(), tests[i].values, tests[i].class)
+				require.NoError(t, err)
+			}
+
+			rec := httptest.NewRecorder()
+			_, err := shedder.Acquire(rec, tests[i].values, tests[i].class)
+			assert.ErrorIs(t, err, entity.ErrLoadShed)
+			assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+			assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+		})
+	}
+}
+
+func TestShedderReleaseAndDisabled(t *testing.T) {
+	values := sheddingValues()
+	values.Capacity = 1
+	shedder := NewShedder()
+
+	release, err := shedder.Acquire(httptest.NewRecorder(), values, "paid")
+	require.NoError(t, err)
+	_, err = shedder.Acquire(httptest.NewRecorder(), values, "paid")
+	require.ErrorIs(t, err, entity.ErrLoadShed)
+
+	release()
+	_, err = shedder.Acquire(httptest.NewRecorder(), values, "paid")
+	require.NoError(t, err, "a released slot is free again")
+
+	_, err = shedder.Acquire(httptest.NewRecorder(), config.SheddingValues{}, "paid")
+	assert.NoError(t, err, "no capacity disables the shedding")
+}
+
+func TestPriorityClass(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.RateLimiter.Shedding = sheddingValues()
+	cfg.RateLimiter.Plans = map[string]config.PlanValues{"gold": {Priority: "paid"}, "basic": {}}
+	m, _ := newMiddleware(t, cfg)
+
+	for value, plan := range map[string]string{"gold-key": "gold", "basic-key": "basic"} {
+		key := &entity.ApiKey{Plan: plan, BlockDuration: 60, RateLimiter: entity.RateLimiter{MaxReq: 10, TimeWindow: 60}}
+		key.SetValue(value)
+		_, err := database.NewAPIKeyRedis(m.RedisClient).Save(context.Background(), key)
+		require.NoError(t, err)
+	}
+
+	tests := []struct {
+		name          string
+		route         config.RouteValues
+		apiKey        string
+		header        string
+		expectedClass string
+	}{
+		{name: "no key", expectedClass: "anonymous"},
+		{name: "registered key without plan priority", apiKey: "basic-key", expectedClass: "free"},
+		{name: "plan priority", apiKey: "gold-key", expectedClass: "paid"},
+		{name: "unknown key", apiKey: "made-up", expectedClass: "anonymous"},
+		{name: "route wins over the plan", route: config.RouteValues{Priority: "free"}, apiKey: "gold-key", expectedClass: "free"},
+		{name: "header lowers the plan class", apiKey: "gold-key", header: "anonymous", expectedClass: "anonymous"},
+		{name: "header lowers the route class", route: config.RouteValues{Priority: "paid"}, header: "Free", expectedClass: "free"},
+		{name: "header can't raise the class", header: "paid", expectedClass: "anonymous"},
+		{name: "header can't raise an unknown key", apiKey: "made-up", header: "paid", expectedClass: "anonymous"},
+		{name: "unknown header class is ignored", apiKey: "basic-key", header: "vip", expectedClass: "free"},
+	}
+
+	for i := 0; i < len(tests); i++ {
+		t.Run(tests[i].name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tests[i].header != "" {
+				req.Header.Set("X-Priority", tests[i].header)
+			}
+
+			assert.Equal(t, tests[i].expectedClass, m.priorityClass(req, cfg, tests[i].route, tests[i].apiKey))
+		})
+	}
+}
+
+func TestPriorityClassUnavailableRedis(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.RateLimiter.Shedding = sheddingValues()
+	m, redisServer := newMiddleware(t, cfg)
+	redisServer.Close()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	assert.Equal(t, "anonymous", m.priorityClass(req, cfg, config.RouteValues{}, "any-key"))
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/database"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/tracing"
+)
+
+// Storage hands out the repositories used by the strategies, they are traced and measured
+type Storage interface {
+	IPRepository(namespace string) entity.IPRepository
+
+	ApiKeyRepository() entity.ApiKeyRepository
+
+	SemaphoreRepository() entity.SemaphoreRepository
+}
+
+func (m *Middleware) IPRepository(namespace string) entity.IPRepository {
+	repository := tracing.IPRepository(database.NewIPRedisWithNamespace(m.RedisClient, namespace).WithCIDRBlocks(m.CIDRBlocks))
+	if m.Metrics != nil {
+		repository = m.Metrics.IPRepository(repository)
+	}
+
+	return repository
+}
+
+func (m *Middleware) ApiKeyRepository() entity.ApiKeyRepository {
+	repository := tracing.ApiKeyRepository(database.NewAPIKeyRedis(m.RedisClient))
+	if m.Metrics != nil {
+		repository = m.Metrics.ApiKeyRepository(repository)
+	}
+
+	return repository
+}
+
+func (m *Middleware) SemaphoreRepository() entity.SemaphoreRepository {
+	repository := tracing.SemaphoreRepository(database.NewSemaphoreRedis(m.RedisClient))
+	if m.Metrics != nil {
+		repository = m.Metrics.SemaphoreRepository(repository)
+	}
+
+	return repository
+}
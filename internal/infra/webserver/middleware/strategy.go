@@ -1,17 +1,97 @@
 package middleware
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/MatheusBenetti/rate-limiter/config"
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/database"
 )
 
 type StrategyMiddleware interface {
 	Execute(w http.ResponseWriter, r *http.Request) error
+
+	// Take records the request only when it fits the limit now and never blocks the identity,
+	// otherwise it returns how long until the request would fit
+	Take(w http.ResponseWriter, r *http.Request) (time.Duration, error)
+
+	// Charge records an extra cost for a request that was already served
+	Charge(r *http.Request, cost int) error
 }
 
-func Factory(apiKey string, m *Middleware) StrategyMiddleware {
+// errTooManyRequests is returned by the strategies when the request goes over the limit
+var errTooManyRequests = errors.New("too many request")
+
+func Factory(apiKey string, cost int, route string, cfg *config.Config, m *Middleware) StrategyMiddleware {
 	if apiKey != "" {
-		return &APIKeyMiddleware{RedisClient: m.RedisClient, ApiKey: apiKey}
+		return &APIKeyMiddleware{Storage: m, Config: cfg, ApiKey: apiKey, Cost: cost, Factor: m.Adaptive.Factor()}
 	}
 
-	return &IPMiddleware{RedisClient: m.RedisClient, Config: m.Config}
+	return &IPMiddleware{
+		Storage: m,
+		Metrics: m.Metrics,
+		Config:  cfg,
+		Cost:    cost,
+		Shadow:  cfg.RateLimiter.ByIp.Shadow,
+		Route:   route,
+		Factor:  m.Adaptive.Factor(),
+	}
+}
+
+// setLimitHeaders tells the client the budget of its time window and how much of it is left
+func setLimitHeaders(w http.ResponseWriter, limit, remaining int) {
+	w.Header().Set(entity.LimitHeader, strconv.Itoa(limit))
+	w.Header().Set(entity.RemainingHeader, strconv.Itoa(remaining))
+}
+
+// strategyName labels the strategy picked by Factory
+func strategyName(apiKey string) string {
+	if apiKey != "" {
+		return "api_key"
+	}
+
+	return "ip"
+}
+
+// identityKey names the identity a request is limited by, the API key when there is one or else the IP
+func identityKey(r *http.Request, apiKey string) string {
+	if apiKey != "" {
+		return fmt.Sprintf("api-key_%s", apiKey)
+	}
+
+	return fmt.Sprintf("ip_%s", getIP(r.RemoteAddr))
+}
+
+// undecidedError wraps the storage failures that kept the limiter from reaching a decision,
+// no response is written for them so the fail mode of the route can answer
+type undecidedError struct {
+	err error
+}
+
+func (e *undecidedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *undecidedError) Unwrap() error {
+	return e.err
+}
+
+// undecided leaves the decision to the fail mode when the storage could not be reached, any other error
+// comes from a request the storage did answer and rejects it, so a client can't fail open on purpose
+func undecided(w http.ResponseWriter, err error) error {
+	if !database.Unavailable(err) {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return err
+	}
+
+	return &undecidedError{err: err}
+}
+
+func isUndecided(err error) bool {
+	var undecidedErr *undecidedError
+	return errors.As(err, &undecidedErr)
 }
@@ -1,10 +1,13 @@
 package webserver
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"time"
 
+	"github.com/MatheusBenetti/rate-limiter/config"
 	internalMw "github.com/MatheusBenetti/rate-limiter/internal/infra/webserver/middleware"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -14,20 +17,33 @@ type HandlerProps struct {
 	Method string
 	Path   string
 	Func   http.HandlerFunc
+	Exempt bool
 }
 
+// Timeouts used when the configuration leaves them out. There is no write timeout by default since it
+// would cut long responses, streams and proxied calls, operators opt in with app.write_timeout
+const (
+	DefaultReadTimeout       = 15 * time.Second
+	DefaultReadHeaderTimeout = 5 * time.Second
+	DefaultWriteTimeout      = 0
+	DefaultIdleTimeout       = 60 * time.Second
+	DefaultShutdownTimeout   = 30 * time.Second
+)
+
 type WebServer struct {
 	WebServerPort      string
 	Router             chi.Router
 	Handlers           []HandlerProps
 	InternalMiddleware internalMw.Middleware
+	App                config.App
 }
 
-func NewWebServer(serverPort string) *WebServer {
+func NewWebServer(app config.App) *WebServer {
 	return &WebServer{
 		Router:        chi.NewRouter(),
 		Handlers:      make([]HandlerProps, 0),
-		WebServerPort: fmt.Sprintf("0.0.0.0:%s", serverPort),
+		WebServerPort: fmt.Sprintf("0.0.0.0:%s", app.Port),
+		App:           app,
 	}
 }
 
@@ -39,15 +55,93 @@ func (s *WebServer) AddHandler(method, path string, handler http.HandlerFunc) {
 	})
 }
 
-func (s *WebServer) Start() {
-	s.Router.Use(middleware.Logger)
-	s.Router.Use(s.InternalMiddleware.RateLimiter)
+// AddProxyHandler registers a handler guarded by the rate limiter for every method and path left
+// without a handler of their own
+func (s *WebServer) AddProxyHandler(handler http.Handler) {
+	s.Handlers = append(s.Handlers, HandlerProps{
+		Path: "/*",
+		Func: handler.ServeHTTP,
+	})
+}
+
+// AddExemptHandler registers a handler that is not guarded by the rate limiter, an empty method matches every method
+func (s *WebServer) AddExemptHandler(method, path string, handler http.HandlerFunc) {
+	s.Handlers = append(s.Handlers, HandlerProps{
+		Method: method,
+		Path:   path,
+		Func:   handler,
+		Exempt: true,
+	})
+}
+
+// Start serves the handlers until ctx is done and then drains the in-flight requests for at most the
+// shutdown timeout. It returns the error that kept the server from starting or from shutting down cleanly
+func (s *WebServer) Start(ctx context.Context) error {
+	s.Router.Use(middleware.RequestID)
+	s.Router.Use(internalMw.AccessLog)
+	s.Router.Group(func(limited chi.Router) {
+		limited.Use(s.InternalMiddleware.RateLimiter)
+		for _, h := range s.Handlers {
+			switch {
+			case h.Exempt:
+			case h.Method == "":
+				limited.Handle(h.Path, h.Func)
+			default:
+				limited.Method(h.Method, h.Path, h.Func)
+			}
+		}
+	})
 	for _, h := range s.Handlers {
-		s.Router.Method(h.Method, h.Path, h.Func)
+		switch {
+		case !h.Exempt:
+		case h.Method == "":
+			s.Router.Handle(h.Path, h.Func)
+		default:
+			s.Router.Method(h.Method, h.Path, h.Func)
+		}
 	}
 
-	if err := http.ListenAndServe(s.WebServerPort, s.Router); err != nil {
-		log.Printf("error starting the server.")
-		return
+	server := &http.Server{
+		Addr:              s.WebServerPort,
+		Handler:           s.Router,
+		ReadTimeout:       seconds(s.App.ReadTimeout, DefaultReadTimeout),
+		ReadHeaderTimeout: seconds(s.App.ReadHeaderTimeout, DefaultReadHeaderTimeout),
+		WriteTimeout:      seconds(s.App.WriteTimeout, DefaultWriteTimeout),
+		IdleTimeout:       seconds(s.App.IdleTimeout, DefaultIdleTimeout),
 	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("error starting the server: %w", err)
+	case <-ctx.Done():
+	}
+
+	shutdownTimeout := ShutdownTimeout(s.App)
+	slog.Info("shutting down web server", "timeout", shutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("error draining the server: %w", err)
+	}
+
+	return nil
+}
+
+// ShutdownTimeout is how long the in-flight requests are drained once the server is asked to stop
+func ShutdownTimeout(app config.App) time.Duration {
+	return seconds(app.ShutdownTimeout, DefaultShutdownTimeout)
+}
+
+func seconds(value int64, fallback time.Duration) time.Duration {
+	if value <= 0 {
+		return fallback
+	}
+
+	return time.Duration(value) * time.Second
 }
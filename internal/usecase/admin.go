@@ -0,0 +1,106 @@
+package usecase
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/MatheusBenetti/rate-limiter/internal/dto"
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+)
+
+// ManageBlocks holds the support operations over blocks and counters
+type ManageBlocks struct {
+	adminRepository entity.AdminRepository
+}
+
+func NewManageBlocksUseCase(adminRepository entity.AdminRepository) *ManageBlocks {
+	return &ManageBlocks{
+		adminRepository: adminRepository,
+	}
+}
+
+// Block blocks an IP, CIDR range or API key for the input duration in seconds
+func (mb *ManageBlocks) Block(ctx context.Context, input dto.BlockInput) (dto.BlockOutput, error) {
+	block := entity.Block{
+		Kind:      input.Kind,
+		Namespace: input.Namespace,
+		Identity:  input.Identity,
+		Reason:    input.Reason,
+		TTL:       time.Duration(input.Duration) * time.Second,
+	}
+	if valErr := block.Validate(); valErr != nil {
+		return dto.BlockOutput{}, valErr
+	}
+
+	if blockErr := mb.adminRepository.Block(ctx, &block); blockErr != nil {
+		slog.ErrorContext(ctx, "error blocking identity", "kind", block.Kind, "identity", block.Identity, "error", blockErr)
+		return dto.BlockOutput{}, blockErr
+	}
+
+	slog.InfoContext(ctx, "manually blocked identity",
+		"kind", block.Kind, "namespace", block.Namespace, "identity", block.Identity, "ttl", block.TTL, "reason", block.Reason)
+	return blockOutput(block), nil
+}
+
+// Unblock lifts an active block, it returns ErrBlockNotFound when the identity was not blocked
+func (mb *ManageBlocks) Unblock(ctx context.Context, input dto.IdentityInput) error {
+	block := entity.Block{Kind: input.Kind, Namespace: input.Namespace, Identity: input.Identity}
+	if valErr := block.Normalize(); valErr != nil {
+		return valErr
+	}
+
+	removed, unblockErr := mb.adminRepository.Unblock(ctx, block.Kind, block.Namespace, block.Identity)
+	if unblockErr != nil {
+		slog.ErrorContext(ctx, "error unblocking identity", "kind", block.Kind, "identity", block.Identity, "error", unblockErr)
+		return unblockErr
+	}
+
+	if !removed {
+		return entity.ErrBlockNotFound
+	}
+
+	slog.InfoContext(ctx, "manually unblocked identity", "kind", block.Kind, "namespace", block.Namespace, "identity", block.Identity)
+	return nil
+}
+
+// Reset drops the window counters of an IP or API key
+func (mb *ManageBlocks) Reset(ctx context.Context, input dto.IdentityInput) error {
+	block := entity.Block{Kind: input.Kind, Namespace: input.Namespace, Identity: input.Identity}
+	if valErr := block.Normalize(); valErr != nil {
+		return valErr
+	}
+
+	if resetErr := mb.adminRepository.Reset(ctx, block.Kind, block.Namespace, block.Identity); resetErr != nil {
+		slog.ErrorContext(ctx, "error resetting identity", "kind", block.Kind, "identity", block.Identity, "error", resetErr)
+		return resetErr
+	}
+
+	return nil
+}
+
+func (mb *ManageBlocks) List(ctx context.Context) ([]dto.BlockOutput, error) {
+	blocks, listErr := mb.adminRepository.ListBlocked(ctx)
+	if listErr != nil {
+		slog.ErrorContext(ctx, "error listing blocks", "error", listErr)
+		return nil, listErr
+	}
+
+	output := make([]dto.BlockOutput, 0, len(blocks))
+	for _, block := range blocks {
+		output = append(output, blockOutput(block))
+	}
+
+	return output, nil
+}
+
+func blockOutput(block entity.Block) dto.BlockOutput {
+	return dto.BlockOutput{
+		Kind:      block.Kind,
+		Namespace: block.Namespace,
+		Identity:  block.Identity,
+		Reason:    block.Reason,
+		TTL:       int64(math.Ceil(block.TTL.Seconds())),
+	}
+}
@@ -2,75 +2,278 @@ package usecase
 
 import (
 	"context"
-	"log"
+	"log/slog"
+	"time"
 
+	"github.com/MatheusBenetti/rate-limiter/config"
 	"github.com/MatheusBenetti/rate-limiter/internal/dto"
 	"github.com/MatheusBenetti/rate-limiter/internal/entity"
 )
 
 type RegisterApiKey struct {
 	apiRepository entity.ApiKeyRepository
+	config        *config.Config
+	factor        float64
 }
 
 func NewRegisterAPIKeyUseCase(
 	apiRepository entity.ApiKeyRepository,
+	config *config.Config,
 ) *RegisterApiKey {
 	return &RegisterApiKey{
 		apiRepository: apiRepository,
+		config:        config,
 	}
 }
 
+// WithLimitFactor scales the maximum requests of the keys by the adaptive factor
+func (apk *RegisterApiKey) WithLimitFactor(factor float64) *RegisterApiKey {
+	apk.factor = factor
+	return apk
+}
+
 func (apk *RegisterApiKey) Execute(
 	ctx context.Context,
 	input dto.ApiKeyReq,
 ) (dto.ApiKeyAllow, error) {
+	cost, costErr := requestCost(input.Cost)
+	if costErr != nil {
+		return dto.ApiKeyAllow{}, costErr
+	}
+
 	status, blockedErr := apk.apiRepository.GetBlockedDuration(ctx, input.Value)
 	if blockedErr != nil {
 		return dto.ApiKeyAllow{}, blockedErr
 	}
 
 	if status == entity.StatusApiKeyBlock {
-		log.Println("API key is blocked due to exceeding the maximum number of requests")
+		slog.DebugContext(ctx, "api key is blocked due to exceeding the maximum number of requests")
 		return dto.ApiKeyAllow{}, entity.ErrApiKeyAmountReq
 	}
 
 	apiKeyConfig, getErr := apk.apiRepository.Get(ctx, input.Value)
 	if getErr != nil {
-		log.Println("API key get error:", getErr.Error())
+		slog.ErrorContext(ctx, "error getting API key", "error", getErr)
 		return dto.ApiKeyAllow{}, getErr
 	}
 
 	rateLimReq, getReqErr := apk.apiRepository.GetRequest(ctx, input.Value)
 	if getReqErr != nil {
-		log.Printf("Error getting IP requests: %s \n", getReqErr.Error())
+		slog.ErrorContext(ctx, "error getting API key requests", "error", getReqErr)
 		return dto.ApiKeyAllow{}, getReqErr
 	}
 
 	rateLimReq.TimeWindow = apiKeyConfig.RateLimiter.TimeWindow
-	rateLimReq.MaxReq = apiKeyConfig.RateLimiter.MaxReq
+	rateLimReq.MaxReq = entity.ScaleLimit(apiKeyConfig.RateLimiter.MaxReq, apk.factor)
 	if valErr := rateLimReq.Validate(); valErr != nil {
-		log.Printf("Error validation in rate limiter: %s \n", valErr.Error())
+		slog.ErrorContext(ctx, "error validation in rate limiter", "error", valErr)
 		return dto.ApiKeyAllow{}, valErr
 	}
 
-	rateLimReq.AddReq(input.TimeAdded)
+	rateLimReq.AddWeightedReq(input.TimeAdded, cost)
 	isAllowed := rateLimReq.Allow(input.TimeAdded)
 	if upsertErr := apk.apiRepository.UpsertRequest(ctx, input.Value, rateLimReq); upsertErr != nil {
-		log.Printf("Error updating/inserting rate limit: %s \n", upsertErr.Error())
+		slog.ErrorContext(ctx, "error updating/inserting rate limit", "error", upsertErr)
 		return dto.ApiKeyAllow{}, upsertErr
 	}
 
+	var retryAfter time.Duration
 	if !isAllowed {
+		duration, durationErr := blockDuration(ctx, apk.apiRepository, input.Value, apk.penalty(apiKeyConfig))
+		if durationErr != nil {
+			return dto.ApiKeyAllow{}, durationErr
+		}
+
 		if saveErr := apk.apiRepository.SaveBlockedDuration(
 			ctx,
 			input.Value,
-			apiKeyConfig.BlockDuration,
+			duration,
 		); saveErr != nil {
 			return dto.ApiKeyAllow{}, saveErr
 		}
+		retryAfter = time.Duration(duration) * time.Second
 	}
 
+	reset, _ := rateLimReq.NextSlot(input.TimeAdded, rateLimReq.MaxReq)
 	return dto.ApiKeyAllow{
-		Allow: isAllowed,
+		Allow:      isAllowed,
+		RetryAfter: retryAfter,
+		Reset:      reset,
+		Limit:      rateLimReq.MaxReq,
+		Remaining:  max(rateLimReq.Remaining(input.TimeAdded), 0),
 	}, nil
 }
+
+// Charge records an extra cost for a request that was already served, it never blocks the key:
+// going over the limit only shows up on the next requests
+func (apk *RegisterApiKey) Charge(
+	ctx context.Context,
+	input dto.ApiKeyReq,
+) error {
+	cost, costErr := requestCost(input.Cost)
+	if costErr != nil {
+		return costErr
+	}
+
+	apiKeyConfig, getErr := apk.apiRepository.Get(ctx, input.Value)
+	if getErr != nil {
+		slog.ErrorContext(ctx, "error getting API key", "error", getErr)
+		return getErr
+	}
+
+	rateLimReq, getReqErr := apk.apiRepository.GetRequest(ctx, input.Value)
+	if getReqErr != nil {
+		slog.ErrorContext(ctx, "error getting API key requests", "error", getReqErr)
+		return getReqErr
+	}
+
+	rateLimReq.TimeWindow = apiKeyConfig.RateLimiter.TimeWindow
+	rateLimReq.MaxReq = entity.ScaleLimit(apiKeyConfig.RateLimiter.MaxReq, apk.factor)
+	rateLimReq.AddWeightedReq(input.TimeAdded, cost)
+	if upsertErr := apk.apiRepository.UpsertRequest(ctx, input.Value, rateLimReq); upsertErr != nil {
+		slog.ErrorContext(ctx, "error updating/inserting rate limit", "error", upsertErr)
+		return upsertErr
+	}
+
+	return nil
+}
+
+// Take records the request only when it fits the budget of the key now, reading and storing the requests as
+// a single operation so concurrent callers can't both take the last slot. A request that does not fit is
+// reported with how long until the next slot frees up and, unlike Execute, it never blocks the key
+func (apk *RegisterApiKey) Take(
+	ctx context.Context,
+	input dto.ApiKeyReq,
+) (dto.ApiKeyAllow, error) {
+	cost, costErr := requestCost(input.Cost)
+	if costErr != nil {
+		return dto.ApiKeyAllow{}, costErr
+	}
+
+	status, blockedErr := apk.apiRepository.GetBlockedDuration(ctx, input.Value)
+	if blockedErr != nil {
+		return dto.ApiKeyAllow{}, blockedErr
+	}
+
+	if status == entity.StatusApiKeyBlock {
+		return dto.ApiKeyAllow{}, entity.ErrApiKeyAmountReq
+	}
+
+	apiKeyConfig, getErr := apk.apiRepository.Get(ctx, input.Value)
+	if getErr != nil {
+		slog.ErrorContext(ctx, "error getting API key", "error", getErr)
+		return dto.ApiKeyAllow{}, getErr
+	}
+
+	var allow dto.ApiKeyAllow
+	var takeErr error
+	if updateErr := apk.apiRepository.UpdateRequest(ctx, input.Value, func(rl *entity.RateLimiter) bool {
+		rl.TimeWindow = apiKeyConfig.RateLimiter.TimeWindow
+		rl.MaxReq = entity.ScaleLimit(apiKeyConfig.RateLimiter.MaxReq, apk.factor)
+		if takeErr = rl.Validate(); takeErr != nil {
+			return false
+		}
+
+		wait, fits := rl.NextSlot(input.TimeAdded, cost)
+		if !fits {
+			takeErr = entity.ErrApiKeyAmountReq
+			return false
+		}
+		if wait == 0 {
+			rl.AddWeightedReq(input.TimeAdded, cost)
+		}
+
+		reset, _ := rl.NextSlot(input.TimeAdded, rl.MaxReq)
+		allow = dto.ApiKeyAllow{
+			Allow:      wait == 0,
+			RetryAfter: wait,
+			Reset:      reset,
+			Limit:      rl.MaxReq,
+			Remaining:  max(rl.Remaining(input.TimeAdded), 0),
+		}
+		return wait == 0
+	}); updateErr != nil {
+		slog.ErrorContext(ctx, "error taking a slot of the rate limit", "error", updateErr)
+		return dto.ApiKeyAllow{}, updateErr
+	}
+	if takeErr != nil {
+		return dto.ApiKeyAllow{}, takeErr
+	}
+
+	return allow, nil
+}
+
+// Peek reports whether the request would be allowed now without recording it,
+// when it would not RetryAfter tells how long until the next slot frees up
+func (apk *RegisterApiKey) Peek(
+	ctx context.Context,
+	input dto.ApiKeyReq,
+) (dto.ApiKeyAllow, error) {
+	cost, costErr := requestCost(input.Cost)
+	if costErr != nil {
+		return dto.ApiKeyAllow{}, costErr
+	}
+
+	status, blockedErr := apk.apiRepository.GetBlockedDuration(ctx, input.Value)
+	if blockedErr != nil {
+		return dto.ApiKeyAllow{}, blockedErr
+	}
+
+	if status == entity.StatusApiKeyBlock {
+		return dto.ApiKeyAllow{}, entity.ErrApiKeyAmountReq
+	}
+
+	apiKeyConfig, getErr := apk.apiRepository.Get(ctx, input.Value)
+	if getErr != nil {
+		slog.ErrorContext(ctx, "error getting API key", "error", getErr)
+		return dto.ApiKeyAllow{}, getErr
+	}
+
+	rateLimReq, getReqErr := apk.apiRepository.GetRequest(ctx, input.Value)
+	if getReqErr != nil {
+		slog.ErrorContext(ctx, "error getting API key requests", "error", getReqErr)
+		return dto.ApiKeyAllow{}, getReqErr
+	}
+
+	rateLimReq.TimeWindow = apiKeyConfig.RateLimiter.TimeWindow
+	rateLimReq.MaxReq = entity.ScaleLimit(apiKeyConfig.RateLimiter.MaxReq, apk.factor)
+	if valErr := rateLimReq.Validate(); valErr != nil {
+		slog.ErrorContext(ctx, "error validation in rate limiter", "error", valErr)
+		return dto.ApiKeyAllow{}, valErr
+	}
+
+	wait, fits := rateLimReq.NextSlot(input.TimeAdded, cost)
+	if !fits {
+		return dto.ApiKeyAllow{}, entity.ErrApiKeyAmountReq
+	}
+
+	reset, _ := rateLimReq.NextSlot(input.TimeAdded, rateLimReq.MaxReq)
+	return dto.ApiKeyAllow{
+		Allow:      wait == 0,
+		RetryAfter: wait,
+		Reset:      reset,
+		Limit:      rateLimReq.MaxReq,
+		Remaining:  max(rateLimReq.Remaining(input.TimeAdded), 0),
+	}, nil
+}
+
+// LongestBlock is the longest block the plan of the key gives, how long a blocked key waits at most
+func (apk *RegisterApiKey) LongestBlock(ctx context.Context, value string) (time.Duration, error) {
+	apiKeyConfig, getErr := apk.apiRepository.Get(ctx, value)
+	if getErr != nil {
+		return 0, getErr
+	}
+
+	return time.Duration(apk.penalty(apiKeyConfig).Longest()) * time.Second, nil
+}
+
+// penalty uses the block schedule of the key plan, keys without a plan are always blocked for their BlockDuration
+func (apk *RegisterApiKey) penalty(apiKey *entity.ApiKey) entity.Penalty {
+	penalty := entity.Penalty{BlockDuration: apiKey.BlockDuration}
+	if plan, ok := apk.config.RateLimiter.Plans[apiKey.Plan]; ok && apiKey.Plan != "" {
+		penalty.Schedule = plan.BlockSchedule
+		penalty.Decay = plan.OffenseDecay
+	}
+
+	return penalty
+}
@@ -0,0 +1,42 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/MatheusBenetti/rate-limiter/config"
+	"github.com/MatheusBenetti/rate-limiter/internal/dto"
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/MatheusBenetti/rate-limiter/internal/entity/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterApiKeyPeekReportsTheBudget(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	repository := mock.NewMockApiKeyRepository(ctrl)
+	now := time.Now()
+
+	repository.EXPECT().GetBlockedDuration(gomock.Any(), "key").Return("", nil)
+	repository.EXPECT().Get(gomock.Any(), "key").Return(&entity.ApiKey{
+		BlockDuration: 60,
+		RateLimiter:   entity.RateLimiter{MaxReq: 3, TimeWindow: 60},
+	}, nil)
+	repository.EXPECT().GetRequest(gomock.Any(), "key").Return(&entity.RateLimiter{
+		Req:  []time.Time{now.Add(-10 * time.Second)},
+		Cost: []int{1},
+	}, nil)
+	// nothing is stored: peeking never records the request
+
+	peek, err := NewRegisterAPIKeyUseCase(repository, &config.Config{}).Peek(context.Background(), dto.ApiKeyReq{
+		Value:     "key",
+		TimeAdded: now,
+	})
+	require.NoError(t, err)
+	assert.True(t, peek.Allow)
+	assert.Equal(t, 3, peek.Limit)
+	assert.Equal(t, 2, peek.Remaining)
+	assert.Equal(t, 50*time.Second, peek.Reset)
+}
@@ -0,0 +1,40 @@
+package usecase
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/MatheusBenetti/rate-limiter/internal/dto"
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+)
+
+type AcquireSlot struct {
+	semaphoreRepository entity.SemaphoreRepository
+}
+
+func NewAcquireSlotUseCase(semaphoreRepository entity.SemaphoreRepository) *AcquireSlot {
+	return &AcquireSlot{
+		semaphoreRepository: semaphoreRepository,
+	}
+}
+
+// Execute takes an in-flight slot, the returned lease must be released once the request is done
+func (as *AcquireSlot) Execute(ctx context.Context, input dto.SlotReq) (*entity.Lease, error) {
+	lease, leaseErr := entity.NewLease(input.Key, input.LeaseTTL)
+	if leaseErr != nil {
+		return nil, leaseErr
+	}
+
+	acquired, acquireErr := as.semaphoreRepository.Acquire(ctx, lease, input.Limit)
+	if acquireErr != nil {
+		slog.ErrorContext(ctx, "error acquiring in-flight slot", "error", acquireErr)
+		return nil, acquireErr
+	}
+
+	if !acquired {
+		slog.DebugContext(ctx, "identity reached the maximum number of simultaneous requests")
+		return nil, entity.ErrConcurrencyLimit
+	}
+
+	return lease, nil
+}
@@ -0,0 +1,16 @@
+package usecase
+
+import "github.com/MatheusBenetti/rate-limiter/internal/entity"
+
+// requestCost normalizes the cost of a request, an unset cost counts as a single request
+func requestCost(cost int) (int, error) {
+	if cost < 0 {
+		return 0, entity.ErrRequestCost
+	}
+
+	if cost == 0 {
+		return 1, nil
+	}
+
+	return cost, nil
+}
@@ -2,22 +2,32 @@ package usecase
 
 import (
 	"context"
-	"log"
+	"log/slog"
 
+	"github.com/MatheusBenetti/rate-limiter/config"
 	"github.com/MatheusBenetti/rate-limiter/internal/dto"
 	"github.com/MatheusBenetti/rate-limiter/internal/entity"
 )
 
 type CreateApiKeyUseCase struct {
 	apiKeyRepository entity.ApiKeyRepository
+	config           *config.Config
 }
 
-func NewCreateAPIKeyUseCase(apiKeyRepository entity.ApiKeyRepository) *CreateApiKeyUseCase {
-	return &CreateApiKeyUseCase{apiKeyRepository: apiKeyRepository}
+func NewCreateAPIKeyUseCase(apiKeyRepository entity.ApiKeyRepository, config *config.Config) *CreateApiKeyUseCase {
+	return &CreateApiKeyUseCase{apiKeyRepository: apiKeyRepository, config: config}
 }
 
 func (cr *CreateApiKeyUseCase) Execute(ctx context.Context, input dto.Input) (dto.Output, error) {
+	if input.Plan != "" {
+		if _, ok := cr.config.RateLimiter.Plans[input.Plan]; !ok {
+			slog.WarnContext(ctx, "error on CreateAPIKeyUseCase unknown plan", "plan", input.Plan)
+			return dto.Output{}, entity.ErrUnknownPlan
+		}
+	}
+
 	apiKey := entity.ApiKey{
+		Plan:          input.Plan,
 		BlockDuration: input.BlockDuration,
 		RateLimiter: entity.RateLimiter{
 			TimeWindow: input.TimeWindow,
@@ -26,17 +36,17 @@ func (cr *CreateApiKeyUseCase) Execute(ctx context.Context, input dto.Input) (dt
 	}
 
 	if err := apiKey.GenerateValue(); err != nil {
-		log.Printf("Error on CreateAPIKeyUseCase generating key value: %s\n", err.Error())
+		slog.ErrorContext(ctx, "error on CreateAPIKeyUseCase generating key value", "error", err)
 		return dto.Output{}, err
 	}
 
 	keyValue, saveErr := cr.apiKeyRepository.Save(ctx, &apiKey)
 	if saveErr != nil {
-		log.Printf("Error on CreateAPIKeyUseCase saving data: %s\n", saveErr.Error())
+		slog.ErrorContext(ctx, "error on CreateAPIKeyUseCase saving data", "error", saveErr)
 		return dto.Output{}, saveErr
 	}
 
-	log.Println("Saved with success")
+	slog.InfoContext(ctx, "api key saved with success", "plan", input.Plan)
 	return dto.Output{
 		Api_Key: keyValue,
 	}, nil
@@ -0,0 +1,196 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/MatheusBenetti/rate-limiter/config"
+	"github.com/MatheusBenetti/rate-limiter/internal/dto"
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+)
+
+// Decide evaluates the descriptors of the decision API by the named policies of the configuration,
+// every policy keeps its counters in a namespace of its own
+type Decide struct {
+	repository func(namespace string) entity.IPRepository
+	decisions  config.Decisions
+}
+
+func NewDecideUseCase(repository func(namespace string) entity.IPRepository, decisions config.Decisions) *Decide {
+	return &Decide{
+		repository: repository,
+		decisions:  decisions,
+	}
+}
+
+// descriptor is a validated descriptor input bound to the limiter of its policy
+type descriptor struct {
+	input   dto.DescriptorInput
+	policy  config.LimitValues
+	limiter *RegisterIP
+}
+
+// Check decides on a single descriptor as a batch of one, so both take the quota the same way and a denied
+// descriptor never blocks its identity. With Peek it only reports the state of the budget
+func (d *Decide) Check(ctx context.Context, input dto.CheckInput) (dto.DecisionOutput, error) {
+	output, err := d.Batch(ctx, dto.BatchCheckInput{
+		Descriptors: []dto.DescriptorInput{input.DescriptorInput},
+		Peek:        input.Peek,
+	})
+	if err != nil {
+		return dto.DecisionOutput{}, err
+	}
+
+	return output.Descriptors[0], nil
+}
+
+// Batch decides on the descriptors all or nothing. Each descriptor takes its quota as a single operation
+// on its counter and, as soon as one does not fit, the ones already taken are given back, so the quota is
+// kept only when every descriptor fits. A denied descriptor never blocks its identity, the state of each
+// one is reported instead
+func (d *Decide) Batch(ctx context.Context, input dto.BatchCheckInput) (dto.BatchCheckOutput, error) {
+	if len(input.Descriptors) == 0 {
+		return dto.BatchCheckOutput{}, entity.ErrEmptyBatch
+	}
+
+	descriptors := make([]descriptor, 0, len(input.Descriptors))
+	seen := make(map[string]bool, len(input.Descriptors))
+	for _, in := range input.Descriptors {
+		desc, err := d.descriptor(in)
+		if err != nil {
+			return dto.BatchCheckOutput{}, err
+		}
+
+		key := fmt.Sprintf("%s\x00%s", desc.input.Policy, desc.input.Identity)
+		if seen[key] {
+			return dto.BatchCheckOutput{}, fmt.Errorf("%w: %s %s", entity.ErrDuplicateDescriptor, in.Policy, in.Identity)
+		}
+		seen[key] = true
+		descriptors = append(descriptors, desc)
+	}
+
+	now := time.Now()
+	if input.Peek {
+		return evaluate(ctx, descriptors, now, descriptor.peek)
+	}
+
+	output := dto.BatchCheckOutput{Allowed: true}
+	for i, desc := range descriptors {
+		decision, err := desc.take(ctx, now)
+		if err == nil && decision.Allowed {
+			output.Descriptors = append(output.Descriptors, decision)
+			continue
+		}
+
+		if refundErr := refund(ctx, descriptors[:i], now); refundErr != nil {
+			return dto.BatchCheckOutput{}, refundErr
+		}
+		if err != nil {
+			return dto.BatchCheckOutput{}, err
+		}
+
+		// the others are reported as they are once the quota was given back
+		denied, peekErr := evaluate(ctx, descriptors, now, descriptor.peek)
+		if peekErr != nil {
+			return dto.BatchCheckOutput{}, peekErr
+		}
+		denied.Allowed = false
+		denied.Descriptors[i] = decision
+		return denied, nil
+	}
+
+	return output, nil
+}
+
+// refund gives back the quota taken by the descriptors
+func refund(ctx context.Context, descriptors []descriptor, now time.Time) error {
+	for _, desc := range descriptors {
+		if err := desc.limiter.Refund(ctx, dto.IpReq{IP: desc.input.Identity, TimeAdded: now, Cost: desc.input.Cost}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func evaluate(
+	ctx context.Context,
+	descriptors []descriptor,
+	now time.Time,
+	decide func(descriptor, context.Context, time.Time) (dto.DecisionOutput, error),
+) (dto.BatchCheckOutput, error) {
+	output := dto.BatchCheckOutput{Allowed: true}
+	for _, desc := range descriptors {
+		decision, err := decide(desc, ctx, now)
+		if err != nil {
+			return dto.BatchCheckOutput{}, err
+		}
+
+		output.Allowed = output.Allowed && decision.Allowed
+		output.Descriptors = append(output.Descriptors, decision)
+	}
+
+	return output, nil
+}
+
+func (d *Decide) descriptor(input dto.DescriptorInput) (descriptor, error) {
+	input.Policy = strings.ToLower(input.Policy)
+	if input.Policy == "" || input.Identity == "" {
+		return descriptor{}, entity.ErrInvalidDescriptor
+	}
+	if _, err := requestCost(input.Cost); err != nil {
+		return descriptor{}, err
+	}
+
+	policy, ok := d.decisions.Policy(input.Policy)
+	if !ok {
+		return descriptor{}, fmt.Errorf("%w: %s", entity.ErrUnknownPolicy, input.Policy)
+	}
+
+	return descriptor{
+		input:   input,
+		policy:  policy,
+		limiter: NewRegisterIPPolicyUseCase(d.repository(fmt.Sprintf("decisions:%s", input.Policy)), policy),
+	}, nil
+}
+
+func (desc descriptor) peek(ctx context.Context, now time.Time) (dto.DecisionOutput, error) {
+	allow, err := desc.limiter.Peek(ctx, dto.IpReq{IP: desc.input.Identity, TimeAdded: now, Cost: desc.input.Cost})
+	return desc.output(allow, err)
+}
+
+func (desc descriptor) take(ctx context.Context, now time.Time) (dto.DecisionOutput, error) {
+	allow, err := desc.limiter.Take(ctx, dto.IpReq{IP: desc.input.Identity, TimeAdded: now, Cost: desc.input.Cost})
+	return desc.output(allow, err)
+}
+
+func (desc descriptor) output(allow dto.IpAllow, err error) (dto.DecisionOutput, error) {
+	output := dto.DecisionOutput{
+		Policy:   desc.input.Policy,
+		Identity: desc.input.Identity,
+		Limit:    desc.policy.MaxReq,
+	}
+	if errors.Is(err, entity.ErrIpAmountReq) {
+		// the identity is blocked, or the cost never fits the budget, the block of the policy is the longest it may last
+		output.RetryAfter = desc.policy.LongestBlock()
+		output.Reset = output.RetryAfter
+		return output, nil
+	}
+	if err != nil {
+		return dto.DecisionOutput{}, err
+	}
+
+	output.Allowed = allow.Allow
+	output.Remaining = allow.Remaining
+	output.Reset = ceilSeconds(allow.Reset)
+	output.RetryAfter = ceilSeconds(allow.RetryAfter)
+	return output, nil
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
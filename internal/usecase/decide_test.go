@@ -0,0 +1,155 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MatheusBenetti/rate-limiter/config"
+	"github.com/MatheusBenetti/rate-limiter/internal/dto"
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/database"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// racingRepository runs race once, right before the first update of the stored requests, standing in
+// for another client that takes the quota between the decisions of the batch
+type racingRepository struct {
+	entity.IPRepository
+	race func()
+}
+
+func (r *racingRepository) UpdateRequest(ctx context.Context, key string, update func(rl *entity.RateLimiter) bool) error {
+	if race := r.race; race != nil {
+		r.race = nil
+		race()
+	}
+	return r.IPRepository.UpdateRequest(ctx, key, update)
+}
+
+func newDecideTest(t *testing.T, repository func(namespace string, redisRepository entity.IPRepository) entity.IPRepository) *Decide {
+	t.Helper()
+
+	redisServer := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: redisServer.Addr()})
+	decisions := config.Decisions{Token: "token", Policies: map[string]config.LimitValues{
+		"login":  {MaxReq: 5, TimeWindow: 60, BlockDuration: 300},
+		"export": {MaxReq: 1, TimeWindow: 60, BlockDuration: 300},
+	}}
+
+	return NewDecideUseCase(func(namespace string) entity.IPRepository {
+		redisRepository := database.NewIPRedisWithNamespace(client, namespace)
+		if repository == nil {
+			return redisRepository
+		}
+		return repository(namespace, redisRepository)
+	}, decisions)
+}
+
+func TestBatchGivesBackTheQuotaWhenTheRaceIsLost(t *testing.T) {
+	var export *racingRepository
+	decide := newDecideTest(t, func(namespace string, redisRepository entity.IPRepository) entity.IPRepository {
+		if namespace != "decisions:export" {
+			return redisRepository
+		}
+		if export == nil {
+			export = &racingRepository{IPRepository: redisRepository}
+			export.race = func() {
+				rival := NewRegisterIPPolicyUseCase(redisRepository, config.LimitValues{MaxReq: 1, TimeWindow: 60, BlockDuration: 300})
+				allow, err := rival.Execute(context.Background(), dto.IpReq{IP: "tenant:7", TimeAdded: time.Now()})
+				require.NoError(t, err)
+				require.True(t, allow.Allow)
+			}
+		}
+		return export
+	})
+
+	output, err := decide.Batch(context.Background(), dto.BatchCheckInput{Descriptors: []dto.DescriptorInput{
+		{Policy: "login", Identity: "user:42"},
+		{Policy: "export", Identity: "tenant:7"},
+	}})
+	require.NoError(t, err)
+	assert.False(t, output.Allowed)
+	require.Len(t, output.Descriptors, 2)
+	assert.Equal(t, 5, output.Descriptors[0].Remaining, "the quota taken by login is given back")
+	assert.False(t, output.Descriptors[1].Allowed)
+	assert.Positive(t, output.Descriptors[1].RetryAfter)
+
+	status, err := export.GetBlockedDuration(context.Background(), "tenant:7")
+	require.NoError(t, err)
+	assert.Empty(t, status, "the denied identity is not blocked")
+}
+
+func TestBatchConcurrentCallersShareTheLastSlot(t *testing.T) {
+	decide := newDecideTest(t, nil)
+
+	const callers = 10
+	var wg sync.WaitGroup
+	allowed := make(chan int, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			output, err := decide.Batch(context.Background(), dto.BatchCheckInput{Descriptors: []dto.DescriptorInput{
+				{Policy: "login", Identity: fmt.Sprintf("user:%d", i)},
+				{Policy: "export", Identity: "tenant:7"},
+			}})
+			assert.NoError(t, err)
+			if output.Allowed {
+				allowed <- i
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(allowed)
+
+	winners := make(map[int]bool)
+	for i := range allowed {
+		winners[i] = true
+	}
+	require.Len(t, winners, 1, "only one batch takes the last export slot")
+
+	for i := 0; i < callers; i++ {
+		peek, err := decide.Check(context.Background(), dto.CheckInput{
+			DescriptorInput: dto.DescriptorInput{Policy: "login", Identity: fmt.Sprintf("user:%d", i)},
+			Peek:            true,
+		})
+		require.NoError(t, err)
+		if winners[i] {
+			assert.Equal(t, 4, peek.Remaining)
+		} else {
+			assert.Equal(t, 5, peek.Remaining, "a denied batch keeps none of its quota")
+		}
+	}
+}
+
+func TestCheckOverTheLimitDoesNotBlock(t *testing.T) {
+	var export entity.IPRepository
+	decide := newDecideTest(t, func(namespace string, redisRepository entity.IPRepository) entity.IPRepository {
+		if namespace == "decisions:export" {
+			export = redisRepository
+		}
+		return redisRepository
+	})
+	check := dto.CheckInput{DescriptorInput: dto.DescriptorInput{Policy: "export", Identity: "tenant:7"}}
+
+	output, err := decide.Check(context.Background(), check)
+	require.NoError(t, err)
+	require.True(t, output.Allowed)
+	assert.Equal(t, 0, output.Remaining)
+
+	output, err = decide.Check(context.Background(), check)
+	require.NoError(t, err)
+	assert.False(t, output.Allowed)
+	assert.Positive(t, output.RetryAfter)
+	assert.LessOrEqual(t, output.RetryAfter, int64(60), "the identity waits for the next slot, not for a block")
+
+	status, err := export.GetBlockedDuration(context.Background(), "tenant:7")
+	require.NoError(t, err)
+	assert.Empty(t, status, "a denied check takes the quota like a batch and never blocks")
+}
@@ -0,0 +1,87 @@
+package usecase
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/MatheusBenetti/rate-limiter/config"
+	"github.com/MatheusBenetti/rate-limiter/internal/dto"
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+)
+
+// RegisterFailure records the outcome of a request after the response was written,
+// only failed attempts count against the limit
+type RegisterFailure struct {
+	repository entity.IPRepository
+	values     config.FailureValues
+}
+
+func NewRegisterFailureUseCase(
+	repository entity.IPRepository,
+	values config.FailureValues,
+) *RegisterFailure {
+	return &RegisterFailure{
+		repository: repository,
+		values:     values,
+	}
+}
+
+// CheckBlocked returns ErrTooManyFailures while the identity is blocked
+func (rf *RegisterFailure) CheckBlocked(ctx context.Context, identity string) error {
+	status, blockedErr := rf.repository.GetBlockedDuration(ctx, identity)
+	if blockedErr != nil {
+		return blockedErr
+	}
+
+	if status == entity.StatusIPBlocked {
+		slog.DebugContext(ctx, "identity is blocked due to exceeding the maximum number of failed attempts")
+		return entity.ErrTooManyFailures
+	}
+
+	return nil
+}
+
+func (rf *RegisterFailure) Execute(ctx context.Context, input dto.FailureReq) error {
+	if !rf.values.Counts(input.Status) {
+		if rf.values.ResetOnSuccess && input.Status >= 200 && input.Status < 300 {
+			return rf.repository.DeleteRequest(ctx, input.Identity)
+		}
+		return nil
+	}
+
+	if rf.values.BlockDuration == 0 {
+		return entity.ErrBlockTimeDuration
+	}
+
+	// the failure is counted as a single operation, concurrent failures can't overwrite each other
+	var valErr error
+	var exceeded bool
+	if updateErr := rf.repository.UpdateRequest(ctx, input.Identity, func(failures *entity.RateLimiter) bool {
+		failures.TimeWindow = rf.values.TimeWindow
+		failures.MaxReq = rf.values.MaxFailures
+		if valErr = failures.Validate(); valErr != nil {
+			return false
+		}
+
+		failures.AddReq(input.TimeAdded)
+		exceeded = failures.Remaining(input.TimeAdded) <= 0
+		return !exceeded
+	}); updateErr != nil {
+		slog.ErrorContext(ctx, "error updating/inserting failed requests", "error", updateErr)
+		return updateErr
+	}
+	if valErr != nil {
+		slog.ErrorContext(ctx, "error validation in failure limiter", "error", valErr)
+		return valErr
+	}
+	if !exceeded {
+		return nil
+	}
+
+	if saveErr := rf.repository.SaveBlockedDuration(ctx, input.Identity, rf.values.BlockDuration); saveErr != nil {
+		return saveErr
+	}
+
+	// the identity starts over with a clean slate once the block expires
+	return rf.repository.DeleteRequest(ctx, input.Identity)
+}
@@ -0,0 +1,151 @@
+package usecase
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MatheusBenetti/rate-limiter/config"
+	"github.com/MatheusBenetti/rate-limiter/internal/dto"
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/MatheusBenetti/rate-limiter/internal/entity/mock"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/database"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterFailureExecute(t *testing.T) {
+	values := config.FailureValues{
+		Status:         []int{http.StatusUnauthorized},
+		MaxFailures:    2,
+		TimeWindow:     60,
+		BlockDuration:  300,
+		ResetOnSuccess: true,
+	}
+	now := time.Now()
+
+	tests := []struct {
+		name        string
+		status      int
+		stored      *entity.RateLimiter
+		expectWrite bool
+		expect      func(repository *mock.MockIPRepository)
+	}{
+		{
+			name:   "status that is not a failure",
+			status: http.StatusNotFound,
+			expect: func(*mock.MockIPRepository) {},
+		},
+		{
+			name:   "success resets the failures",
+			status: http.StatusOK,
+			expect: func(repository *mock.MockIPRepository) {
+				repository.EXPECT().DeleteRequest(gomock.Any(), "user:1").Return(nil)
+			},
+		},
+		{
+			name:        "failure below the maximum",
+			status:      http.StatusUnauthorized,
+			stored:      &entity.RateLimiter{},
+			expectWrite: true,
+			expect:      func(*mock.MockIPRepository) {},
+		},
+		{
+			name:   "failure reaching the maximum blocks the identity",
+			status: http.StatusUnauthorized,
+			stored: &entity.RateLimiter{Req: []time.Time{now}},
+			expect: func(repository *mock.MockIPRepository) {
+				repository.EXPECT().SaveBlockedDuration(gomock.Any(), "user:1", int64(300)).Return(nil)
+				repository.EXPECT().DeleteRequest(gomock.Any(), "user:1").Return(nil)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			repository := mock.NewMockIPRepository(ctrl)
+			if tt.stored != nil {
+				repository.EXPECT().UpdateRequest(gomock.Any(), "user:1", gomock.Any()).DoAndReturn(
+					func(_ context.Context, _ string, update func(*entity.RateLimiter) bool) error {
+						require.Equal(t, tt.expectWrite, update(tt.stored))
+						return nil
+					},
+				)
+			}
+			tt.expect(repository)
+
+			err := NewRegisterFailureUseCase(repository, values).Execute(context.Background(), dto.FailureReq{
+				Identity:  "user:1",
+				TimeAdded: now,
+				Status:    tt.status,
+			})
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestRegisterFailureConcurrentFailures(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxFailures   int
+		expectBlocked bool
+	}{
+		{name: "every failure is counted", maxFailures: 1000},
+		{name: "parallel failures trip the block", maxFailures: 5, expectBlocked: true},
+	}
+
+	for i := 0; i < len(tests); i++ {
+		t.Run(tests[i].name, func(t *testing.T) {
+			redisServer := miniredis.RunT(t)
+			repository := database.NewIPRedisWithNamespace(redis.NewClient(&redis.Options{Addr: redisServer.Addr()}), "failures/login")
+			failureReq := NewRegisterFailureUseCase(repository, config.FailureValues{
+				Status:        []int{http.StatusUnauthorized},
+				MaxFailures:   tests[i].maxFailures,
+				TimeWindow:    60,
+				BlockDuration: 300,
+			})
+
+			const failures = 50
+			var wg sync.WaitGroup
+			for j := 0; j < failures; j++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					assert.NoError(t, failureReq.Execute(context.Background(), dto.FailureReq{
+						Identity:  "10.0.0.1",
+						TimeAdded: time.Now(),
+						Status:    http.StatusUnauthorized,
+					}))
+				}()
+			}
+			wg.Wait()
+
+			blockedErr := failureReq.CheckBlocked(context.Background(), "10.0.0.1")
+			if tests[i].expectBlocked {
+				require.ErrorIs(t, blockedErr, entity.ErrTooManyFailures)
+				return
+			}
+			require.NoError(t, blockedErr)
+			stored, err := repository.GetRequest(context.Background(), "10.0.0.1")
+			require.NoError(t, err)
+			assert.Len(t, stored.Req, failures)
+		})
+	}
+}
+
+func TestRegisterFailureCheckBlocked(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	repository := mock.NewMockIPRepository(ctrl)
+	repository.EXPECT().GetBlockedDuration(gomock.Any(), "user:1").Return(entity.StatusIPBlocked, nil)
+	repository.EXPECT().GetBlockedDuration(gomock.Any(), "user:2").Return("", nil)
+
+	failureReq := NewRegisterFailureUseCase(repository, config.FailureValues{})
+	require.ErrorIs(t, failureReq.CheckBlocked(context.Background(), "user:1"), entity.ErrTooManyFailures)
+	require.NoError(t, failureReq.CheckBlocked(context.Background(), "user:2"))
+}
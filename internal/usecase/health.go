@@ -0,0 +1,58 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/MatheusBenetti/rate-limiter/config"
+	"github.com/MatheusBenetti/rate-limiter/internal/dto"
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+)
+
+const (
+	StatusReady    = "ready"
+	StatusNotReady = "not_ready"
+	ConfigValid    = "valid"
+)
+
+// CheckHealth reports whether the limiter is able to decide on requests
+type CheckHealth struct {
+	healthRepository entity.HealthRepository
+	config           *config.Config
+}
+
+func NewCheckHealthUseCase(healthRepository entity.HealthRepository, config *config.Config) *CheckHealth {
+	return &CheckHealth{
+		healthRepository: healthRepository,
+		config:           config,
+	}
+}
+
+// Execute checks the configuration and every limiter backend. The limiter is ready with a valid
+// configuration and a healthy redis, or with the in-memory fallback enabled while redis is down
+func (ch *CheckHealth) Execute(ctx context.Context) (dto.HealthOutput, bool) {
+	output := dto.HealthOutput{Config: ConfigValid}
+	configErr := ch.config.Validate()
+	if configErr != nil {
+		output.Config = configErr.Error()
+	}
+
+	redis := dto.BackendOutput{Name: "redis", Healthy: true}
+	if pingErr := ch.healthRepository.Ping(ctx); pingErr != nil {
+		redis.Healthy = false
+		redis.Error = pingErr.Error()
+	}
+	output.Backends = append(output.Backends, redis)
+
+	fallback := ch.config.RateLimiter.Breaker.Fallback
+	if fallback {
+		output.Backends = append(output.Backends, dto.BackendOutput{Name: "memory", Healthy: true})
+	}
+
+	ready := configErr == nil && (redis.Healthy || fallback)
+	output.Status = StatusNotReady
+	if ready {
+		output.Status = StatusReady
+	}
+
+	return output, ready
+}
@@ -0,0 +1,82 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/MatheusBenetti/rate-limiter/config"
+	"github.com/MatheusBenetti/rate-limiter/internal/dto"
+	"github.com/MatheusBenetti/rate-limiter/internal/entity/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckHealthExecute(t *testing.T) {
+	pingErr := errors.New("dial tcp: connection refused")
+	redisUp := dto.BackendOutput{Name: "redis", Healthy: true}
+	redisDown := dto.BackendOutput{Name: "redis", Error: pingErr.Error()}
+	memory := dto.BackendOutput{Name: "memory", Healthy: true}
+
+	tests := []struct {
+		name          string
+		port          string
+		fallback      bool
+		pingErr       error
+		expectedReady bool
+		expected      dto.HealthOutput
+	}{
+		{
+			name:          "redis up",
+			port:          "8080",
+			expectedReady: true,
+			expected:      dto.HealthOutput{Status: StatusReady, Config: ConfigValid, Backends: []dto.BackendOutput{redisUp}},
+		},
+		{
+			name:          "redis down without fallback",
+			port:          "8080",
+			pingErr:       pingErr,
+			expectedReady: false,
+			expected:      dto.HealthOutput{Status: StatusNotReady, Config: ConfigValid, Backends: []dto.BackendOutput{redisDown}},
+		},
+		{
+			name:          "redis down with fallback",
+			port:          "8080",
+			fallback:      true,
+			pingErr:       pingErr,
+			expectedReady: true,
+			expected: dto.HealthOutput{
+				Status:   StatusReady,
+				Config:   ConfigValid,
+				Backends: []dto.BackendOutput{redisDown, memory},
+			},
+		},
+		{
+			name:          "invalid config",
+			fallback:      true,
+			expectedReady: false,
+			expected: dto.HealthOutput{
+				Status:   StatusNotReady,
+				Config:   "app.port is required",
+				Backends: []dto.BackendOutput{redisUp, memory},
+			},
+		},
+	}
+
+	for i := 0; i < len(tests); i++ {
+		t.Run(tests[i].name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			repository := mock.NewMockHealthRepository(ctrl)
+			repository.EXPECT().Ping(gomock.Any()).Return(tests[i].pingErr)
+
+			cfg := &config.Config{}
+			cfg.App.Port = tests[i].port
+			cfg.RateLimiter.ByIp = config.LimitValues{MaxReq: 10, TimeWindow: 1, BlockDuration: 60}
+			cfg.RateLimiter.Breaker.Fallback = tests[i].fallback
+
+			output, ready := NewCheckHealthUseCase(repository, cfg).Execute(context.Background())
+			assert.Equal(t, tests[i].expectedReady, ready)
+			assert.Equal(t, tests[i].expected, output)
+		})
+	}
+}
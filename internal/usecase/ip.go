@@ -2,7 +2,8 @@ package usecase
 
 import (
 	"context"
-	"log"
+	"log/slog"
+	"time"
 
 	"github.com/MatheusBenetti/rate-limiter/config"
 	"github.com/MatheusBenetti/rate-limiter/internal/dto"
@@ -12,6 +13,8 @@ import (
 type RegisterIP struct {
 	ipRepository entity.IPRepository
 	config       *config.Config
+	limits       *config.LimitValues
+	factor       float64
 }
 
 func NewRegisterIPUseCase(
@@ -24,51 +27,263 @@ func NewRegisterIPUseCase(
 	}
 }
 
+// NewRegisterIPPolicyUseCase limits the requests by the given policy instead of the by_ip one
+func NewRegisterIPPolicyUseCase(
+	ipRepository entity.IPRepository,
+	limits config.LimitValues,
+) *RegisterIP {
+	return &RegisterIP{
+		ipRepository: ipRepository,
+		limits:       &limits,
+	}
+}
+
+// WithLimitFactor scales the maximum requests of the policy by the adaptive factor
+func (ipr *RegisterIP) WithLimitFactor(factor float64) *RegisterIP {
+	ipr.factor = factor
+	return ipr
+}
+
+func (ipr *RegisterIP) policy() config.LimitValues {
+	var policy config.LimitValues
+	if ipr.limits != nil {
+		policy = *ipr.limits
+	} else {
+		policy = ipr.config.RateLimiter.ByIp
+	}
+	policy.MaxReq = entity.ScaleLimit(policy.MaxReq, ipr.factor)
+
+	return policy
+}
+
 func (ipr *RegisterIP) Execute(
 	ctx context.Context,
 	input dto.IpReq,
 ) (dto.IpAllow, error) {
+	cost, costErr := requestCost(input.Cost)
+	if costErr != nil {
+		return dto.IpAllow{}, costErr
+	}
+
 	status, blockedErr := ipr.ipRepository.GetBlockedDuration(ctx, input.IP)
 	if blockedErr != nil {
 		return dto.IpAllow{}, blockedErr
 	}
 
 	if status == entity.StatusIPBlocked {
-		log.Println("ip is blocked due to exceeding the maximum number of requests")
+		slog.DebugContext(ctx, "ip is blocked due to exceeding the maximum number of requests")
 		return dto.IpAllow{}, entity.ErrIpAmountReq
 	}
 
 	getReq, getReqErr := ipr.ipRepository.GetRequest(ctx, input.IP)
 	if getReqErr != nil {
-		log.Printf("Error getting IP requests: %s \n", getReqErr.Error())
+		slog.ErrorContext(ctx, "error getting IP requests", "error", getReqErr)
 		return dto.IpAllow{}, getReqErr
 	}
 
-	getReq.TimeWindow = ipr.config.RateLimiter.ByIp.TimeWindow
-	getReq.MaxReq = ipr.config.RateLimiter.ByIp.MaxReq
+	policy := ipr.policy()
+	getReq.TimeWindow = policy.TimeWindow
+	getReq.MaxReq = policy.MaxReq
 	if valErr := getReq.Validate(); valErr != nil {
-		log.Printf("Error validation in rate limiter: %s \n", valErr.Error())
+		slog.ErrorContext(ctx, "error validation in rate limiter", "error", valErr)
 		return dto.IpAllow{}, valErr
 	}
 
-	getReq.AddReq(input.TimeAdded)
+	getReq.AddWeightedReq(input.TimeAdded, cost)
 	isAllowed := getReq.Allow(input.TimeAdded)
 	if upsertErr := ipr.ipRepository.UpsertRequest(ctx, input.IP, getReq); upsertErr != nil {
-		log.Printf("Error updating/inserting rate limit: %s \n", upsertErr.Error())
+		slog.ErrorContext(ctx, "error updating/inserting rate limit", "error", upsertErr)
 		return dto.IpAllow{}, upsertErr
 	}
 
+	var retryAfter time.Duration
 	if !isAllowed {
+		duration, durationErr := blockDuration(ctx, ipr.ipRepository, input.IP, entity.Penalty{
+			BlockDuration: policy.BlockDuration,
+			Schedule:      policy.BlockSchedule,
+			Decay:         policy.OffenseDecay,
+		})
+		if durationErr != nil {
+			return dto.IpAllow{}, durationErr
+		}
+
 		if saveErr := ipr.ipRepository.SaveBlockedDuration(
 			ctx,
 			input.IP,
-			ipr.config.RateLimiter.ByIp.BlockDuration,
+			duration,
 		); saveErr != nil {
 			return dto.IpAllow{}, saveErr
 		}
+		retryAfter = time.Duration(duration) * time.Second
+	}
+
+	reset, _ := getReq.NextSlot(input.TimeAdded, policy.MaxReq)
+	return dto.IpAllow{
+		Allow:      isAllowed,
+		RetryAfter: retryAfter,
+		Reset:      reset,
+		Limit:      policy.MaxReq,
+		Remaining:  max(getReq.Remaining(input.TimeAdded), 0),
+	}, nil
+}
+
+// Charge records an extra cost for a request that was already served, it never blocks the identity:
+// going over the limit only shows up on the next requests
+func (ipr *RegisterIP) Charge(
+	ctx context.Context,
+	input dto.IpReq,
+) error {
+	cost, costErr := requestCost(input.Cost)
+	if costErr != nil {
+		return costErr
+	}
+
+	getReq, getReqErr := ipr.ipRepository.GetRequest(ctx, input.IP)
+	if getReqErr != nil {
+		slog.ErrorContext(ctx, "error getting IP requests", "error", getReqErr)
+		return getReqErr
+	}
+
+	policy := ipr.policy()
+	getReq.TimeWindow = policy.TimeWindow
+	getReq.MaxReq = policy.MaxReq
+	getReq.AddWeightedReq(input.TimeAdded, cost)
+	if upsertErr := ipr.ipRepository.UpsertRequest(ctx, input.IP, getReq); upsertErr != nil {
+		slog.ErrorContext(ctx, "error updating/inserting rate limit", "error", upsertErr)
+		return upsertErr
+	}
+
+	return nil
+}
+
+// Take records the request only when it fits the budget now, reading and storing the requests as a single
+// operation so concurrent callers can't both take the last slot. A request that does not fit is reported
+// with how long until the next slot frees up and, unlike Execute, it never blocks the identity
+func (ipr *RegisterIP) Take(
+	ctx context.Context,
+	input dto.IpReq,
+) (dto.IpAllow, error) {
+	cost, costErr := requestCost(input.Cost)
+	if costErr != nil {
+		return dto.IpAllow{}, costErr
+	}
+
+	status, blockedErr := ipr.ipRepository.GetBlockedDuration(ctx, input.IP)
+	if blockedErr != nil {
+		return dto.IpAllow{}, blockedErr
+	}
+
+	if status == entity.StatusIPBlocked {
+		return dto.IpAllow{}, entity.ErrIpAmountReq
+	}
+
+	policy := ipr.policy()
+	var allow dto.IpAllow
+	var takeErr error
+	if updateErr := ipr.ipRepository.UpdateRequest(ctx, input.IP, func(rl *entity.RateLimiter) bool {
+		rl.TimeWindow = policy.TimeWindow
+		rl.MaxReq = policy.MaxReq
+		if takeErr = rl.Validate(); takeErr != nil {
+			return false
+		}
+
+		wait, fits := rl.NextSlot(input.TimeAdded, cost)
+		if !fits {
+			takeErr = entity.ErrIpAmountReq
+			return false
+		}
+		if wait == 0 {
+			rl.AddWeightedReq(input.TimeAdded, cost)
+		}
+
+		reset, _ := rl.NextSlot(input.TimeAdded, policy.MaxReq)
+		allow = dto.IpAllow{
+			Allow:      wait == 0,
+			RetryAfter: wait,
+			Reset:      reset,
+			Limit:      policy.MaxReq,
+			Remaining:  max(rl.Remaining(input.TimeAdded), 0),
+		}
+		return wait == 0
+	}); updateErr != nil {
+		slog.ErrorContext(ctx, "error taking a slot of the rate limit", "error", updateErr)
+		return dto.IpAllow{}, updateErr
+	}
+	if takeErr != nil {
+		return dto.IpAllow{}, takeErr
+	}
+
+	return allow, nil
+}
+
+// Refund gives back the cost of a request recorded by Take at input.TimeAdded
+func (ipr *RegisterIP) Refund(
+	ctx context.Context,
+	input dto.IpReq,
+) error {
+	cost, costErr := requestCost(input.Cost)
+	if costErr != nil {
+		return costErr
+	}
+
+	policy := ipr.policy()
+	if updateErr := ipr.ipRepository.UpdateRequest(ctx, input.IP, func(rl *entity.RateLimiter) bool {
+		rl.TimeWindow = policy.TimeWindow
+		rl.MaxReq = policy.MaxReq
+		return rl.RemoveWeightedReq(input.TimeAdded, cost)
+	}); updateErr != nil {
+		slog.ErrorContext(ctx, "error refunding the rate limit", "error", updateErr)
+		return updateErr
+	}
+
+	return nil
+}
+
+// Peek reports whether the request would be allowed now and the state of the budget without recording it,
+// when it would not RetryAfter tells how long until the next slot frees up
+func (ipr *RegisterIP) Peek(
+	ctx context.Context,
+	input dto.IpReq,
+) (dto.IpAllow, error) {
+	cost, costErr := requestCost(input.Cost)
+	if costErr != nil {
+		return dto.IpAllow{}, costErr
+	}
+
+	status, blockedErr := ipr.ipRepository.GetBlockedDuration(ctx, input.IP)
+	if blockedErr != nil {
+		return dto.IpAllow{}, blockedErr
+	}
+
+	if status == entity.StatusIPBlocked {
+		return dto.IpAllow{}, entity.ErrIpAmountReq
+	}
+
+	getReq, getReqErr := ipr.ipRepository.GetRequest(ctx, input.IP)
+	if getReqErr != nil {
+		slog.ErrorContext(ctx, "error getting IP requests", "error", getReqErr)
+		return dto.IpAllow{}, getReqErr
+	}
+
+	policy := ipr.policy()
+	getReq.TimeWindow = policy.TimeWindow
+	getReq.MaxReq = policy.MaxReq
+	if valErr := getReq.Validate(); valErr != nil {
+		slog.ErrorContext(ctx, "error validation in rate limiter", "error", valErr)
+		return dto.IpAllow{}, valErr
+	}
+
+	wait, fits := getReq.NextSlot(input.TimeAdded, cost)
+	if !fits {
+		return dto.IpAllow{}, entity.ErrIpAmountReq
 	}
 
+	reset, _ := getReq.NextSlot(input.TimeAdded, policy.MaxReq)
 	return dto.IpAllow{
-		Allow: isAllowed,
+		Allow:      wait == 0,
+		RetryAfter: wait,
+		Reset:      reset,
+		Limit:      policy.MaxReq,
+		Remaining:  max(getReq.Remaining(input.TimeAdded), 0),
 	}, nil
 }
@@ -0,0 +1,34 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/MatheusBenetti/rate-limiter/config"
+	"github.com/MatheusBenetti/rate-limiter/internal/dto"
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/MatheusBenetti/rate-limiter/internal/entity/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterIPChargeNeverBlocks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	repository := mock.NewMockIPRepository(ctrl)
+	now := time.Now()
+
+	stored := &entity.RateLimiter{Req: []time.Time{now, now}, Cost: []int{1, 1}}
+	repository.EXPECT().GetRequest(gomock.Any(), "10.0.0.1").Return(stored, nil)
+	repository.EXPECT().UpsertRequest(gomock.Any(), "10.0.0.1", gomock.Any()).DoAndReturn(
+		func(_ context.Context, _ string, rl *entity.RateLimiter) error {
+			require.Equal(t, []int{1, 1, 5}, rl.Cost)
+			return nil
+		},
+	)
+	// no SaveBlockedDuration nor IncrOffense is expected: a charge over the limit must not block
+
+	ipReq := NewRegisterIPPolicyUseCase(repository, config.LimitValues{MaxReq: 3, TimeWindow: 60, BlockDuration: 60})
+	err := ipReq.Charge(context.Background(), dto.IpReq{IP: "10.0.0.1", TimeAdded: now, Cost: 5})
+	require.NoError(t, err)
+}
@@ -0,0 +1,36 @@
+package usecase
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+)
+
+type offenseRepository interface {
+	IncrOffense(ctx context.Context, key string, decay int64) (int64, error)
+}
+
+// blockDuration returns how long the key should be blocked, a progressive penalty counts one more offense
+func blockDuration(
+	ctx context.Context,
+	repository offenseRepository,
+	key string,
+	penalty entity.Penalty,
+) (int64, error) {
+	if valErr := penalty.Validate(); valErr != nil {
+		return 0, valErr
+	}
+
+	if !penalty.Progressive() {
+		return penalty.BlockDuration, nil
+	}
+
+	offense, incrErr := repository.IncrOffense(ctx, key, penalty.DecayDuration())
+	if incrErr != nil {
+		slog.ErrorContext(ctx, "error counting offense", "error", incrErr)
+		return 0, incrErr
+	}
+
+	return penalty.DurationFor(offense), nil
+}
@@ -0,0 +1,156 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/database"
+	"github.com/redis/go-redis/v9"
+)
+
+// ResetHeader tells the client in how many seconds its whole budget is available again
+const ResetHeader = "X-RateLimit-Reset"
+
+// MiddlewareOption customizes the middleware built by NewMiddleware
+type MiddlewareOption func(*middlewareOptions)
+
+type middlewareOptions struct {
+	key      func(r *http.Request) string
+	apiKey   func(ctx context.Context, apiKey string) (bool, error)
+	failOpen bool
+	denied   http.Handler
+}
+
+// WithKeyFunc names the key a request is limited by, by default the remote IP. Requests with an empty key are not limited
+func WithKeyFunc(key func(r *http.Request) string) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.key = key
+	}
+}
+
+// WithAPIKey limits the requests sending the API_KEY header by their key, known tells whether the key is valid.
+// The client chooses the header, so a key that is not known is answered with 401 and never gets a budget of its own.
+// RegisteredAPIKeys accepts the keys registered in the service
+func WithAPIKey(known func(ctx context.Context, apiKey string) (bool, error)) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.apiKey = known
+	}
+}
+
+// RegisteredAPIKeys tells whether an API key is registered in the service sharing the redis of client
+func RegisteredAPIKeys(client redis.UniversalClient) func(ctx context.Context, apiKey string) (bool, error) {
+	repository := database.NewAPIKeyRedis(client)
+
+	return func(ctx context.Context, apiKey string) (bool, error) {
+		_, err := repository.Get(ctx, apiKey)
+		if errors.Is(err, entity.ErrUnknownApiKey) {
+			return false, nil
+		}
+
+		return err == nil, err
+	}
+}
+
+// WithFailOpen lets the requests through when the limiter fails, by default they are answered with 503
+func WithFailOpen() MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.failOpen = true
+	}
+}
+
+// WithDeniedHandler answers the requests over the limit, by default with 429. The rate limit headers are set before
+func WithDeniedHandler(handler http.Handler) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.denied = handler
+	}
+}
+
+// NewMiddleware limits the requests of a net/http server, it sets the X-RateLimit-Limit, X-RateLimit-Remaining
+// and X-RateLimit-Reset headers on every response and Retry-After on the rejected ones
+func NewMiddleware(limiter Limiter, opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	options := &middlewareOptions{
+		key: defaultKey,
+		denied: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+		}),
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// failed answers a request the limiter could not decide on, the fail mode only applies when
+			// the storage could not be reached
+			failed := func(err error) {
+				slog.ErrorContext(r.Context(), "rate limiter could not decide on the request", "error", err)
+				if !database.Unavailable(err) {
+					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+					return
+				}
+				if options.failOpen {
+					next.ServeHTTP(w, r)
+					return
+				}
+				http.Error(w, entity.ErrBackendUnavailable.Error(), http.StatusServiceUnavailable)
+			}
+
+			key := options.key(r)
+			if apiKey := r.Header.Get(entity.ApiKeyHeader); options.apiKey != nil && apiKey != "" {
+				known, err := options.apiKey(r.Context(), apiKey)
+				if err != nil {
+					failed(err)
+					return
+				}
+				if !known {
+					http.Error(w, entity.ErrUnknownApiKey.Error(), http.StatusUnauthorized)
+					return
+				}
+				key = "api-key_" + apiKey
+			}
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			decision, err := limiter.Allow(r.Context(), key)
+			if err != nil {
+				failed(err)
+				return
+			}
+
+			w.Header().Set(entity.LimitHeader, strconv.Itoa(decision.Limit))
+			w.Header().Set(entity.RemainingHeader, strconv.Itoa(decision.Remaining))
+			w.Header().Set(ResetHeader, ceilSeconds(decision.Reset))
+			if !decision.Allowed {
+				if decision.RetryAfter > 0 {
+					w.Header().Set("Retry-After", ceilSeconds(decision.RetryAfter))
+				}
+				options.denied.ServeHTTP(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func defaultKey(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return "ip_" + ip
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
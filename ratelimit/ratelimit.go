@@ -0,0 +1,28 @@
+// Package ratelimit exposes the limiter of the rate limiter service to other Go services, so they can
+// limit their own requests in process instead of going through the service. The limiters share the
+// algorithms and the storage of the service, a limiter backed by redis sees the same state from every replica
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Decision is the outcome of a Limiter for one request
+type Decision struct {
+	// Allowed tells whether the request fits the limit
+	Allowed bool
+	// Limit is the budget of the key in a window
+	Limit int
+	// Remaining is how much of the budget is left in the current window
+	Remaining int
+	// Reset is how long until the whole budget of the key is available again
+	Reset time.Duration
+	// RetryAfter is how long a rejected key has to wait, zero when it is not known
+	RetryAfter time.Duration
+}
+
+// Limiter decides whether the requests of a key are allowed
+type Limiter interface {
+	Allow(ctx context.Context, key string) (Decision, error)
+}
@@ -0,0 +1,244 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/database"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlidingWindowAllow(t *testing.T) {
+	limiter, err := NewSlidingWindow(NewMemoryStore(), 2, time.Second, WithBlock(time.Minute))
+	require.NoError(t, err)
+
+	for remaining := 1; remaining >= 0; remaining-- {
+		decision, err := limiter.Allow(context.Background(), "key")
+		require.NoError(t, err)
+		require.True(t, decision.Allowed)
+		require.Equal(t, 2, decision.Limit)
+		require.Equal(t, remaining, decision.Remaining)
+	}
+
+	decision, err := limiter.Allow(context.Background(), "key")
+	require.NoError(t, err)
+	require.False(t, decision.Allowed)
+	require.Equal(t, time.Minute, decision.RetryAfter)
+
+	decision, err = limiter.Allow(context.Background(), "other")
+	require.NoError(t, err)
+	require.True(t, decision.Allowed, "keys should not share their budget")
+}
+
+func TestNewSlidingWindowInvalid(t *testing.T) {
+	_, err := NewSlidingWindow(NewMemoryStore(), 0, time.Second)
+	require.Error(t, err)
+
+	_, err = NewSlidingWindow(NewMemoryStore(), 1, 0)
+	require.Error(t, err)
+}
+
+func TestMiddleware(t *testing.T) {
+	limiter, err := NewSlidingWindow(NewMemoryStore(), 1, time.Second)
+	require.NoError(t, err)
+	handler := NewMiddleware(limiter)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "1", rec.Header().Get("X-RateLimit-Limit"))
+	require.Equal(t, "0", rec.Header().Get("X-RateLimit-Remaining"))
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.NotEmpty(t, rec.Header().Get("Retry-After"))
+}
+
+func TestMiddlewareKeys(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: redisServer.Addr()})
+	registered := &entity.ApiKey{BlockDuration: 60, RateLimiter: entity.RateLimiter{MaxReq: 10, TimeWindow: 60}}
+	registered.SetValue("registered")
+	_, err := database.NewAPIKeyRedis(client).Save(context.Background(), registered)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		opts           []MiddlewareOption
+		apiKey         string
+		expectedStatus int
+	}{
+		{name: "header is ignored by default", apiKey: "made-up", expectedStatus: http.StatusTooManyRequests},
+		{name: "registered key has its own budget", opts: []MiddlewareOption{WithAPIKey(RegisteredAPIKeys(client))},
+			apiKey: "registered", expectedStatus: http.StatusOK},
+		{name: "unknown key is rejected", opts: []MiddlewareOption{WithAPIKey(RegisteredAPIKeys(client))},
+			apiKey: "made-up", expectedStatus: http.StatusUnauthorized},
+	}
+
+	for i := 0; i < len(tests); i++ {
+		t.Run(tests[i].name, func(t *testing.T) {
+			limiter, err := NewSlidingWindow(NewMemoryStore(), 1, time.Minute)
+			require.NoError(t, err)
+			handler := NewMiddleware(limiter, tests[i].opts...)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			require.Equal(t, http.StatusOK, rec.Code, "the first request takes the budget of the IP")
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(entity.ApiKeyHeader, tests[i].apiKey)
+			rec = httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, tests[i].expectedStatus, rec.Code)
+		})
+	}
+}
+
+func TestTransport(t *testing.T) {
+	var calls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	limiter, err := NewSlidingWindow(NewMemoryStore(), 10, time.Second)
+	require.NoError(t, err)
+
+	failFast := &http.Client{Transport: NewTransport(nil, limiter, WithFailFast())}
+	res, err := failFast.Get(upstream.URL)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusTooManyRequests, res.StatusCode)
+
+	_, err = failFast.Get(upstream.URL)
+	require.ErrorIs(t, err, ErrLimited, "the host should be paused after the 429")
+
+	waiting := &http.Client{Transport: NewTransport(nil, limiter)}
+	res, err = waiting.Get(upstream.URL)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, int32(2), calls.Load())
+}
+
+// slowRepository delays the reads of the requests, standing in for the round trip to a shared store
+// that lets the replicas interleave
+type slowRepository struct {
+	entity.IPRepository
+}
+
+func (r slowRepository) GetRequest(ctx context.Context, key string) (*entity.RateLimiter, error) {
+	time.Sleep(20 * time.Millisecond)
+	return r.IPRepository.GetRequest(ctx, key)
+}
+
+// closeTracker is a request body that tells whether it was closed
+type closeTracker struct {
+	*strings.Reader
+	closed atomic.Bool
+}
+
+func (c *closeTracker) Close() error {
+	c.closed.Store(true)
+	return nil
+}
+
+func TestTransportClosesTheBodyOfRejectedRequests(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		opts        []TransportOption
+		expectedErr error
+	}{
+		{name: "fail fast", ctx: context.Background(), opts: []TransportOption{WithFailFast()}, expectedErr: ErrLimited},
+		{name: "canceled while waiting", ctx: canceled, expectedErr: context.Canceled},
+	}
+
+	for i := 0; i < len(tests); i++ {
+		t.Run(tests[i].name, func(t *testing.T) {
+			limiter, err := NewSlidingWindow(NewMemoryStore(), 1, time.Minute)
+			require.NoError(t, err)
+			transport := NewTransport(nil, limiter, tests[i].opts...)
+
+			res, err := transport.RoundTrip(httptest.NewRequest(http.MethodGet, upstream.URL, nil))
+			require.NoError(t, err)
+			require.NoError(t, res.Body.Close())
+
+			body := &closeTracker{Reader: strings.NewReader("payload")}
+			req, err := http.NewRequestWithContext(tests[i].ctx, http.MethodPost, upstream.URL, body)
+			require.NoError(t, err)
+			_, err = transport.RoundTrip(req)
+			require.ErrorIs(t, err, tests[i].expectedErr)
+			assert.True(t, body.closed.Load(), "the body of a request that is never sent is closed")
+		})
+	}
+}
+
+func TestTransportRaceForTheLastSlotDoesNotBlock(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	memory := database.NewIPMemory()
+	store := &Store{repository: func(string) entity.IPRepository { return slowRepository{IPRepository: memory} }}
+	limiter, err := NewSlidingWindow(store, 2, time.Minute, WithBlock(time.Hour))
+	require.NoError(t, err)
+	client := &http.Client{Transport: NewTransport(nil, limiter, WithFailFast())}
+
+	const callers = 8
+	var sent, limited atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := client.Get(upstream.URL)
+			if errors.Is(err, ErrLimited) {
+				limited.Add(1)
+				return
+			}
+			if assert.NoError(t, err) {
+				_ = res.Body.Close()
+				sent.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, int32(2), sent.Load())
+	require.Equal(t, int32(callers-2), limited.Load())
+
+	decision, err := limiter.Peek(context.Background(), strings.TrimPrefix(upstream.URL, "http://"))
+	require.NoError(t, err)
+	require.False(t, decision.Allowed)
+	require.LessOrEqual(t, decision.RetryAfter, time.Minute, "the losers wait for the window instead of blocking the host")
+}
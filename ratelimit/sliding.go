@@ -0,0 +1,165 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/MatheusBenetti/rate-limiter/config"
+	"github.com/MatheusBenetti/rate-limiter/internal/dto"
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/MatheusBenetti/rate-limiter/internal/usecase"
+)
+
+// SlidingWindow allows up to limit units of cost for each key in any window of time. A key that goes over
+// the limit is blocked, by default for one window, and every request is rejected until the block ends
+type SlidingWindow struct {
+	useCase    *usecase.RegisterIP
+	repository entity.IPRepository
+	policy     config.LimitValues
+}
+
+// Option customizes a SlidingWindow
+type Option func(*slidingOptions)
+
+type slidingOptions struct {
+	name     string
+	block    time.Duration
+	schedule []time.Duration
+	decay    time.Duration
+}
+
+// WithName keeps the keys of the limiter apart from the other limiters of the same store, limiters
+// with the same name share their counters
+func WithName(name string) Option {
+	return func(o *slidingOptions) {
+		o.name = name
+	}
+}
+
+// WithBlock sets for how long a key that went over the limit is blocked
+func WithBlock(duration time.Duration) Option {
+	return func(o *slidingOptions) {
+		o.block = duration
+	}
+}
+
+// WithProgressiveBlock blocks the repeat offenders for each step of the schedule in turn, an offense is
+// forgotten decay after the last one
+func WithProgressiveBlock(schedule []time.Duration, decay time.Duration) Option {
+	return func(o *slidingOptions) {
+		o.schedule = schedule
+		o.decay = decay
+	}
+}
+
+// NewSlidingWindow builds the limiter, window and the block durations are rounded up to whole seconds
+func NewSlidingWindow(store *Store, limit int, window time.Duration, opts ...Option) (*SlidingWindow, error) {
+	if limit <= 0 {
+		return nil, errors.New("ratelimit: limit must be greater than zero")
+	}
+	if window <= 0 {
+		return nil, errors.New("ratelimit: window must be greater than zero")
+	}
+
+	options := &slidingOptions{name: "default", block: window}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	policy := config.LimitValues{
+		MaxReq:        limit,
+		TimeWindow:    seconds(window),
+		BlockDuration: seconds(options.block),
+		OffenseDecay:  seconds(options.decay),
+	}
+	for _, step := range options.schedule {
+		policy.BlockSchedule = append(policy.BlockSchedule, seconds(step))
+	}
+	if policy.BlockDuration == 0 && len(policy.BlockSchedule) == 0 {
+		return nil, errors.New("ratelimit: block must be greater than zero")
+	}
+
+	repository := store.repository(fmt.Sprintf("%s:%s", namespacePrefix, options.name))
+	return &SlidingWindow{
+		useCase:    usecase.NewRegisterIPPolicyUseCase(repository, policy),
+		repository: repository,
+		policy:     policy,
+	}, nil
+}
+
+func (s *SlidingWindow) Allow(ctx context.Context, key string) (Decision, error) {
+	return s.AllowN(ctx, key, 1)
+}
+
+// AllowN records a request that consumes cost units of the budget of the key
+func (s *SlidingWindow) AllowN(ctx context.Context, key string, cost int) (Decision, error) {
+	execute, err := s.useCase.Execute(ctx, dto.IpReq{
+		IP:        key,
+		TimeAdded: time.Now(),
+		Cost:      cost,
+	})
+
+	return s.decision(execute, err)
+}
+
+func (s *SlidingWindow) Peek(ctx context.Context, key string) (Decision, error) {
+	return s.PeekN(ctx, key, 1)
+}
+
+// PeekN reports the decision AllowN would take without consuming the budget, when the request
+// does not fit RetryAfter tells how long until it does
+func (s *SlidingWindow) PeekN(ctx context.Context, key string, cost int) (Decision, error) {
+	peek, err := s.useCase.Peek(ctx, dto.IpReq{
+		IP:        key,
+		TimeAdded: time.Now(),
+		Cost:      cost,
+	})
+
+	return s.decision(peek, err)
+}
+
+// take consumes one unit of the budget of the key only when it fits now, as a single operation on the store,
+// a request that does not fit is told how long to wait and, unlike AllowN, never blocks the key
+func (s *SlidingWindow) take(ctx context.Context, key string) (Decision, error) {
+	take, err := s.useCase.Take(ctx, dto.IpReq{
+		IP:        key,
+		TimeAdded: time.Now(),
+		Cost:      1,
+	})
+
+	return s.decision(take, err)
+}
+
+// pause blocks the key for duration, rounded up to whole seconds, on every replica sharing the store
+func (s *SlidingWindow) pause(ctx context.Context, key string, duration time.Duration) error {
+	return s.repository.SaveBlockedDuration(ctx, key, seconds(duration))
+}
+
+func (s *SlidingWindow) decision(execute dto.IpAllow, err error) (Decision, error) {
+	if errors.Is(err, entity.ErrIpAmountReq) {
+		// the key was already blocked, the block of the policy is the longest it may still last
+		return Decision{
+			Limit:      s.policy.MaxReq,
+			Reset:      time.Duration(s.policy.TimeWindow) * time.Second,
+			RetryAfter: time.Duration(s.policy.LongestBlock()) * time.Second,
+		}, nil
+	}
+	if err != nil {
+		return Decision{}, err
+	}
+
+	return Decision{
+		Allowed:    execute.Allow,
+		Limit:      execute.Limit,
+		Remaining:  execute.Remaining,
+		Reset:      execute.Reset,
+		RetryAfter: execute.RetryAfter,
+	}, nil
+}
+
+func seconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,46 @@
+package ratelimit
+
+import (
+	"sync"
+
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+	"github.com/MatheusBenetti/rate-limiter/internal/infra/database"
+	"github.com/redis/go-redis/v9"
+)
+
+// namespacePrefix keeps the keys of the package apart from the ones of the service sharing the same redis
+const namespacePrefix = "lib"
+
+// Store keeps the requests and the blocks of the keys limited by the limiters built on it
+type Store struct {
+	repository func(namespace string) entity.IPRepository
+}
+
+// NewRedisStore shares the state of the limiters through redis, any client works: single node, sentinel or cluster
+func NewRedisStore(client redis.UniversalClient) *Store {
+	cidrBlocks := database.NewCIDRBlocks(client, database.DefaultCIDRBlocksTTL)
+
+	return &Store{
+		repository: func(namespace string) entity.IPRepository {
+			return database.NewIPRedisWithNamespace(client, namespace).WithCIDRBlocks(cidrBlocks)
+		},
+	}
+}
+
+// NewMemoryStore keeps the state of the limiters in the memory of the process, it is neither shared nor persisted
+func NewMemoryStore() *Store {
+	var lock sync.Mutex
+	memories := make(map[string]*database.IPMemory)
+
+	return &Store{
+		repository: func(namespace string) entity.IPRepository {
+			lock.Lock()
+			defer lock.Unlock()
+
+			if _, ok := memories[namespace]; !ok {
+				memories[namespace] = database.NewIPMemory()
+			}
+			return memories[namespace]
+		},
+	}
+}
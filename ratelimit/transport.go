@@ -0,0 +1,169 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/MatheusBenetti/rate-limiter/internal/entity"
+)
+
+// ErrLimited is returned by the Transport when an outgoing request can't be sent within the wait allowed
+var ErrLimited = errors.New("ratelimit: outgoing request over the limit")
+
+// pollInterval bounds each wait of the Transport, so a key paused by another replica or blocked for
+// longer than its pause is checked again instead of waiting for the whole block
+const pollInterval = time.Second
+
+// Transport throttles the outgoing requests of an http.Client. A request that does not fit the limit of its key
+// waits for the next slot, or fails with ErrLimited, and the key is paused for every replica sharing the store
+// when the upstream answers 429 or reports that its own budget ran out
+type Transport struct {
+	next    http.RoundTripper
+	limiter *SlidingWindow
+	options *transportOptions
+}
+
+// TransportOption customizes the Transport built by NewTransport
+type TransportOption func(*transportOptions)
+
+type transportOptions struct {
+	key     func(r *http.Request) string
+	maxWait time.Duration
+	wait    bool
+}
+
+// WithRequestKey names the key an outgoing request is limited by, by default its destination host
+func WithRequestKey(key func(r *http.Request) string) TransportOption {
+	return func(o *transportOptions) {
+		o.key = key
+	}
+}
+
+// WithFailFast returns ErrLimited right away instead of waiting for the next slot
+func WithFailFast() TransportOption {
+	return func(o *transportOptions) {
+		o.wait = false
+	}
+}
+
+// WithMaxWait returns ErrLimited when the next slot is further away than maxWait, by default
+// a request waits for as long as its context allows
+func WithMaxWait(maxWait time.Duration) TransportOption {
+	return func(o *transportOptions) {
+		o.maxWait = maxWait
+	}
+}
+
+// NewTransport wraps next, http.DefaultTransport when nil. The Transport only takes a slot that fits, so the
+// block of the limiter applies to the pauses asked by the upstream and never to replicas racing for the last slot
+func NewTransport(next http.RoundTripper, limiter *SlidingWindow, opts ...TransportOption) *Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	options := &transportOptions{
+		key: func(r *http.Request) string {
+			return r.URL.Host
+		},
+		wait: true,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	return &Transport{next: next, limiter: limiter, options: options}
+}
+
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	key := t.options.key(r)
+	if err := t.acquire(r, key); err != nil {
+		// a RoundTripper closes the body even when the request is never sent
+		if r.Body != nil {
+			_ = r.Body.Close()
+		}
+		return nil, err
+	}
+
+	res, err := t.next.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if pause := upstreamPause(res); pause > 0 {
+		if pauseErr := t.limiter.pause(r.Context(), key, pause); pauseErr != nil {
+			slog.WarnContext(r.Context(), "error pausing the outgoing requests", "key", key, "error", pauseErr)
+		}
+	}
+
+	return res, nil
+}
+
+// acquire waits until the request fits the limit of key and consumes its slot
+func (t *Transport) acquire(r *http.Request, key string) error {
+	var waited time.Duration
+	for {
+		decision, err := t.limiter.take(r.Context(), key)
+		if err != nil {
+			return err
+		}
+		if decision.Allowed {
+			return nil
+		}
+
+		wait := max(decision.RetryAfter, 10*time.Millisecond)
+		if !t.options.wait || (t.options.maxWait > 0 && waited+wait > t.options.maxWait) {
+			return fmt.Errorf("%w: %s, retry after %s", ErrLimited, key, decision.RetryAfter)
+		}
+
+		wait = min(wait, pollInterval)
+		timer := time.NewTimer(wait)
+		select {
+		case <-r.Context().Done():
+			timer.Stop()
+			return r.Context().Err()
+		case <-timer.C:
+			waited += wait
+		}
+	}
+}
+
+// upstreamPause is how long the upstream asked the key to stop: the Retry-After of a 429, or the
+// X-RateLimit-Reset, in seconds or unix time, else the Retry-After of a response whose X-RateLimit-Remaining reached zero
+func upstreamPause(res *http.Response) time.Duration {
+	retryAfter := parseRetryAfter(res.Header.Get("Retry-After"))
+	if res.StatusCode == http.StatusTooManyRequests {
+		return max(retryAfter, time.Second)
+	}
+
+	if res.Header.Get(entity.RemainingHeader) != "0" {
+		return 0
+	}
+	if reset, err := strconv.ParseInt(res.Header.Get(ResetHeader), 10, 64); err == nil && reset > 0 {
+		// some upstreams send the unix time of the reset instead of the seconds left
+		if reset > time.Now().Add(-24*time.Hour).Unix() {
+			return time.Until(time.Unix(reset, 0))
+		}
+		return time.Duration(reset) * time.Second
+	}
+
+	return retryAfter
+}
+
+// parseRetryAfter reads Retry-After as seconds or as an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at)
+	}
+
+	return 0
+}